@@ -0,0 +1,90 @@
+package main
+
+import (
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// frameDuration is how long each frame of the move animation is shown
+	frameDuration = 60 * time.Millisecond
+	// maxCachedAnimations is the number of icons whose frames are kept
+	maxCachedAnimations = 16
+)
+
+// moveAnimation nudges the tray icon each time the mouse moved. Its frames
+// are rendered once per icon, color and state.
+type moveAnimation struct {
+	// Ticks receives a value when the next frame is due, nil when not playing
+	Ticks <-chan time.Time
+
+	cache  map[trayIcon][][]byte
+	icon   trayIcon
+	frames [][]byte
+	next   int
+	ticker *time.Ticker
+}
+
+// framesOf returns the animation of the icon, from the cache if possible
+func (a *moveAnimation) framesOf(icon trayIcon) ([][]byte, error) {
+	if frames, ok := a.cache[icon]; ok {
+		return frames, nil
+	}
+	style, _ := iconStyle(icon)
+	frames, err := icons.MoveFramesPNG(cachedIcons.file(stateIconName(icon), trayIconSize), style)
+	if err != nil {
+		return nil, err
+	}
+	if a.cache == nil || len(a.cache) >= maxCachedAnimations {
+		a.cache = make(map[trayIcon][][]byte) //the icons in use are rendered again
+	}
+	a.cache[icon] = frames
+	return frames, nil
+}
+
+// play starts the animation of the icon, or starts it over
+func (a *moveAnimation) play(icon trayIcon) {
+	icon = forTray(icon)
+	frames, err := a.framesOf(icon)
+	if err != nil {
+		log.Warnf("Cannot animate icon %v: %v", icon.Name, err)
+		return
+	}
+	a.icon, a.frames, a.next = icon, frames, 0
+	if a.ticker == nil {
+		a.ticker = time.NewTicker(frameDuration)
+		a.Ticks = a.ticker.C
+	}
+	a.step()
+}
+
+// step shows the next frame, and returns false once the animation is over
+func (a *moveAnimation) step() bool {
+	if a.next >= len(a.frames) {
+		a.stop()
+		return false
+	}
+	showIconData(a.icon, a.frames[a.next])
+	a.next++
+	return true
+}
+
+// stop ends the animation, the icon being left on its last frame
+func (a *moveAnimation) stop() {
+	if a.ticker != nil {
+		a.ticker.Stop()
+	}
+	a.ticker, a.Ticks, a.frames = nil, nil, nil
+}
+
+// playing tells if the animation is running
+func (a *moveAnimation) playing() bool {
+	return a.ticker != nil
+}
+
+// clear forgets the frames rendered, e.g. when an icon file changed
+func (a *moveAnimation) clear() {
+	a.cache = nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMoveAnimation(t *testing.T) {
+	setupTestIconDir(t, "mouse")
+	icon := trayIcon{Name: "mouse", Color: "blue", Active: true}
+
+	var animation moveAnimation
+	animation.play(icon)
+	if !animation.playing() || animation.Ticks == nil {
+		t.Fatalf("the animation should be playing")
+	}
+	steps := 1 //the first frame is shown right away
+	for animation.step() {
+		steps++
+	}
+	if steps != len(animation.cache[icon]) {
+		t.Errorf("expected a step per frame, got %d for %d frames", steps, len(animation.cache[icon]))
+	}
+	if animation.playing() || animation.Ticks != nil {
+		t.Errorf("the animation should be over")
+	}
+
+	frames := animation.cache[icon]
+	animation.play(icon)
+	if &animation.frames[0] != &frames[0] {
+		t.Errorf("frames should come from the cache")
+	}
+	animation.stop()
+
+	animation.play(trayIcon{Name: "mouse", Color: "red"})
+	if _, ok := animation.cache[trayIcon{Name: "mouse"}]; !ok {
+		t.Errorf("stopped icons are cached without their color, as shown")
+	}
+	animation.stop()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/autostart"
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
+	"github.com/getlantern/systray"
+	log "github.com/sirupsen/logrus"
+)
+
+// loginMenu is the Start at login checkbox. The file it writes may be
+// changed by the desktop settings, so the checkbox follows the disk.
+type loginMenu struct {
+	item     *systray.MenuItem
+	launcher autostart.Launcher
+	// Clicks receives a value when the checkbox is clicked, nil where the
+	// platform has no launcher
+	Clicks <-chan struct{}
+}
+
+func newLoginMenu(launcher autostart.Launcher) *loginMenu {
+	menu := &loginMenu{launcher: launcher}
+	if launcher == nil {
+		return menu
+	}
+	menu.item = systray.AddMenuItemCheckbox(i18n.T("menu.startAtLogin"), i18n.T("menu.startAtLogin.tooltip"), false)
+	menu.Clicks = menu.item.ClickedCh
+	menu.refresh()
+	return menu
+}
+
+// refresh checks the item if the app starts at login
+func (menu *loginMenu) refresh() {
+	if menu.item == nil {
+		return
+	}
+	enabled, err := menu.launcher.Enabled()
+	if err != nil {
+		log.Warnf("Cannot tell if AMM starts at login: %v", err)
+		return
+	}
+	if enabled != menu.item.Checked() {
+		if enabled {
+			menu.item.Check()
+		} else {
+			menu.item.Uncheck()
+		}
+	}
+}
+
+// toggle starts the app at login, or stops doing so
+func (menu *loginMenu) toggle() {
+	if enabled, err := toggleAutostart(menu.launcher); err != nil {
+		showSettingsError(i18n.T("settings.autostartFailed"), err)
+	} else {
+		log.Infof("Start at login: %v", enabled)
+	}
+	menu.refresh()
+}
+
+// defaultLauncher returns the launcher of the system for this executable,
+// nil if there is none
+func defaultLauncher() autostart.Launcher {
+	executable, err := os.Executable()
+	if err != nil {
+		log.Warnf("Start at login unavailable: %v", err)
+		return nil
+	}
+	return autostart.Default(executable)
+}
+
+// toggleAutostart turns starting at login on if it is off, and off if it is
+// on, returning the new state
+func toggleAutostart(launcher autostart.Launcher) (bool, error) {
+	enabled, err := launcher.Enabled()
+	if err != nil {
+		return false, err
+	}
+	if enabled {
+		return false, launcher.Disable()
+	}
+	return true, launcher.Enable()
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/autostart"
+)
+
+func TestToggleAutostart(t *testing.T) {
+	launcher := autostart.XDG{Dir: filepath.Join(t.TempDir(), "autostart"), Exec: "/usr/bin/amm"}
+	for _, want := range []bool{true, false, true} {
+		enabled, err := toggleAutostart(launcher)
+		if err != nil {
+			t.Fatalf("toggleAutostart: %v", err)
+		}
+		if onDisk, _ := launcher.Enabled(); enabled != want || onDisk != want {
+			t.Fatalf("expected start at login %v, got %v and %v on disk", want, enabled, onDisk)
+		}
+	}
+}
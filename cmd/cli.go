@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/bundle"
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/version"
+)
+
+// cliCommand runs a subcommand of amm with the arguments following its name
+type cliCommand func(args []string, store *config.Store, stdout io.Writer) error
+
+// cliCommands are the subcommands of amm, the tray app starts without one
+var cliCommands = map[string]cliCommand{
+	"profile": profileCommand,
+	"config":  configCommand,
+	"version": versionCommand,
+}
+
+// errUsage is returned by commands called with invalid arguments
+var errUsage = errors.New("invalid arguments")
+
+const profileUsage = `usage:
+  amm profile list
+  amm profile create <name> [-icon name] [-color color] [-paused-color color] [-error-color color] [-blend mode] [-interval seconds]
+                      [-strategy jiggle|nudge] [-schedule "mon,fri 08:00-18:00"]
+  amm profile copy <from> <to>
+  amm profile switch <name>`
+
+const configUsage = `usage:
+  amm config explain [-profile name] [-icon name] [-color name] [-interval seconds] [...]
+  amm config convert [-force] <json|yaml|toml>
+  amm config convert [-force] <from> <to>
+  amm config export <file.zip>
+  amm config import [-dry-run] <file.zip>`
+
+// runCLI runs the subcommand named by args[0] and returns the exit code
+func runCLI(args []string, store *config.Store, stdout, stderr io.Writer) int {
+	command, ok := cliCommands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(cliCommands))
+		for name := range cliCommands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(stderr, "amm: unknown command %q, expected one of: %v\n", args[0], strings.Join(names, ", "))
+		return 2
+	}
+	if err := command(args[1:], store, stdout); err != nil {
+		fmt.Fprintf(stderr, "amm %v: %v\n", args[0], err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// loadForCLI reads the settings, using the defaults if there is no file yet
+func loadForCLI(store *config.Store) (config.AppSettings, error) {
+	settings, _, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	return settings, err
+}
+
+func profileCommand(args []string, store *config.Store, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w\n%v", errUsage, profileUsage)
+	}
+	if args[0] == "list" && len(args) == 1 {
+		//only reads, the file is neither migrated, restored nor rewritten
+		settings, _, err := store.Read()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		listProfiles(settings, stdout)
+		return nil
+	}
+	settings, err := loadForCLI(store)
+	if err != nil {
+		return err
+	}
+	before := settings
+	switch {
+	case args[0] == "create" && len(args) >= 2:
+		p, err := parseProfileFlags(args[2:])
+		if err != nil {
+			return err
+		}
+		if err := settings.CreateProfile(args[1], p); err != nil {
+			return err
+		}
+	case args[0] == "copy" && len(args) == 3:
+		if err := settings.CopyProfile(args[1], args[2]); err != nil {
+			return err
+		}
+	case args[0] == "switch" && len(args) == 2:
+		if err := settings.SwitchProfile(args[1]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w\n%v", errUsage, profileUsage)
+	}
+	policy, err := config.LoadPolicy(layers.PolicyPath)
+	if err != nil {
+		return fmt.Errorf("cannot check the system policy: %w", err)
+	}
+	if err := policy.CheckChange(before, settings); err != nil {
+		return err
+	}
+	return store.Save(settings)
+}
+
+// listProfiles prints the profiles, the active one being marked with a star
+func listProfiles(settings config.AppSettings, stdout io.Writer) {
+	for _, name := range settings.ProfileNames() {
+		p := settings.Profiles[name]
+		marker := " "
+		if name == settings.ActiveProfile {
+			marker = "*"
+		}
+		fmt.Fprintf(stdout, "%v %v\ticon=%v color=%v", marker, name, p.Icon, colorName(p.Color))
+		if p.PausedColor != "" {
+			fmt.Fprintf(stdout, " paused-color=%v", p.PausedColor)
+		}
+		if p.ErrorColor != "" {
+			fmt.Fprintf(stdout, " error-color=%v", p.ErrorColor)
+		}
+		if p.Blend != "" {
+			fmt.Fprintf(stdout, " blend=%v", p.Blend)
+		}
+		fmt.Fprintf(stdout, " interval=%vs", p.IntervalSeconds())
+		if p.Strategy != "" {
+			fmt.Fprintf(stdout, " strategy=%v", p.Strategy)
+		}
+		if p.Schedule != nil {
+			fmt.Fprintf(stdout, " schedule=%q", p.Schedule)
+		}
+		fmt.Fprintln(stdout)
+	}
+}
+
+// configCommand shows the effective settings and where each value came
+// from, the flags given being applied as they would be by the tray app
+func configCommand(args []string, store *config.Store, stdout io.Writer) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "convert":
+			return convertCommand(args[1:], store, stdout)
+		case "export":
+			return exportCommand(args[1:], store, stdout)
+		case "import":
+			return importCommand(args[1:], store, stdout)
+		}
+	}
+	if len(args) == 0 || args[0] != "explain" {
+		return fmt.Errorf("%w\n%v", errUsage, configUsage)
+	}
+	flags := flag.NewFlagSet("config explain", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flagValues := config.OverrideFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v\n%v", errUsage, err, configUsage)
+	}
+	//only reads, the file is neither migrated, restored nor rewritten
+	settings, _, err := store.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	explained := layers
+	explained.Flags = flagValues()
+	resolved := explained.Resolve(settings, store.Path())
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, value := range resolved.Explain() {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", value.Path, value.Value, value.Source)
+	}
+	w.Flush()
+	for _, problem := range resolved.Problems {
+		fmt.Fprintf(stdout, "ignored %v\n", problem)
+	}
+	return nil
+}
+
+// convertCommand writes the settings in another format, either from the
+// settings file to a new one next to it, or between two given files
+func convertCommand(args []string, store *config.Store, stdout io.Writer) error {
+	flags := flag.NewFlagSet("config convert", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	force := flags.Bool("force", false, "overwrite the destination")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v\n%v", errUsage, err, configUsage)
+	}
+	var from, to string
+	switch flags.NArg() {
+	case 1:
+		from = store.Path()
+		to = filepath.Join(filepath.Dir(from), "settings."+flags.Arg(0))
+	case 2:
+		from, to = flags.Arg(0), flags.Arg(1)
+	default:
+		return fmt.Errorf("%w\n%v", errUsage, configUsage)
+	}
+	if _, err := os.Stat(to); err == nil && !*force {
+		return fmt.Errorf("%v already exists, use -force to overwrite it", to)
+	}
+	report, err := config.Convert(from, to)
+	if err != nil {
+		return err
+	}
+	for _, problem := range report.Problems {
+		fmt.Fprintf(stdout, "kept as is %v\n", problem)
+	}
+	fmt.Fprintf(stdout, "wrote %v\n", to)
+	if dir := filepath.Dir(to); from == store.Path() && dir == filepath.Dir(from) {
+		if used := config.FindSettings(dir); used != to {
+			fmt.Fprintf(stdout, "%v is still used, remove it to use %v\n", used, to)
+		} else {
+			fmt.Fprintf(stdout, "%v is now used, %v can be removed\n", to, from)
+		}
+	}
+	return nil
+}
+
+// bundlePaths are the files of the configuration next to the settings file
+func bundlePaths(store *config.Store) bundle.Paths {
+	dir := filepath.Dir(store.Path())
+	return bundle.Paths{
+		Settings: store.Path(),
+		Icons:    filepath.Join(dir, "icons"),
+		Hooks:    filepath.Join(dir, "hooks"),
+	}
+}
+
+// exportCommand writes the settings, icons and hooks to a zip archive
+func exportCommand(args []string, store *config.Store, stdout io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w\n%v", errUsage, configUsage)
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	manifest, err := bundle.Export(bundlePaths(store), f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(args[0])
+		return err
+	}
+	for _, file := range manifest.Files {
+		fmt.Fprintf(stdout, "added %v\n", file.Path)
+	}
+	fmt.Fprintf(stdout, "wrote %v\n", args[0])
+	return nil
+}
+
+// importCommand shows what an archive written by export changes, and
+// applies it unless -dry-run is given
+func importCommand(args []string, store *config.Store, stdout io.Writer) error {
+	flags := flag.NewFlagSet("config import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "only show what would change")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v\n%v", errUsage, err, configUsage)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w\n%v", errUsage, configUsage)
+	}
+	paths := bundlePaths(store)
+	plan, err := bundle.Open(flags.Arg(0), paths)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "archive of %v, created %v\n", plan.Manifest.Host, plan.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"))
+	for _, change := range plan.SettingsChanges {
+		fmt.Fprintf(stdout, "%v\n", change)
+	}
+	for _, file := range plan.Files {
+		fmt.Fprintf(stdout, "%v %v\n", file.Action, file.Path)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	current, err := loadForCLI(store)
+	if err != nil {
+		return err
+	}
+	policy, err := config.LoadPolicy(layers.PolicyPath)
+	if err != nil {
+		return fmt.Errorf("cannot check the system policy: %w", err)
+	}
+	if err := policy.CheckChange(current, plan.Settings); err != nil {
+		return err
+	}
+	if err := plan.Apply(paths); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "imported into %v\n", filepath.Dir(paths.Settings))
+	return nil
+}
+
+// parseProfileFlags reads the values of a new profile, "system" standing
+// for the system color
+func parseProfileFlags(args []string) (config.Profile, error) {
+	p := config.DefaultProfile()
+	flags := flag.NewFlagSet("profile create", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&p.Icon, "icon", p.Icon, "icon of the profile")
+	color := flags.String("color", p.Color, "color of the icon")
+	flags.StringVar(&p.PausedColor, "paused-color", "", "color of the icon while paused")
+	flags.StringVar(&p.ErrorColor, "error-color", "", "color of the icon after a failed move")
+	flags.StringVar(&p.Blend, "blend", "", "how the color is applied: tint, multiply or overlay")
+	flags.IntVar(&p.Interval, "interval", p.Interval, "seconds without activity before moving")
+	flags.StringVar(&p.Strategy, "strategy", "", "how the mouse moves: jiggle or nudge")
+	schedule := flags.String("schedule", "", "hours when the mouse may move, e.g. mon,fri 08:00-18:00")
+	if err := flags.Parse(args); err != nil {
+		return p, fmt.Errorf("%w: %v\n%v", errUsage, err, profileUsage)
+	}
+	if *schedule != "" {
+		var err error
+		if p.Schedule, err = config.ParseBusinessHours(*schedule); err != nil {
+			return p, fmt.Errorf("schedule: %w", err)
+		}
+	}
+	if flags.NArg() > 0 {
+		return p, fmt.Errorf("%w: unexpected %q\n%v", errUsage, flags.Arg(0), profileUsage)
+	}
+	p.Color = *color
+	if p.Color == "system" {
+		p.Color = ""
+	}
+	return p, nil
+}
+
+func colorName(color string) string {
+	if color == "" {
+		return "system"
+	}
+	return color
+}
+
+// versionCommand prints the build, to be copied into bug reports
+func versionCommand(args []string, store *config.Store, stdout io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w\nusage:\n  amm version", errUsage)
+	}
+	info := version.Get()
+	fmt.Fprintf(stdout, "amm %v\n", info.Version)
+	if info.Commit != "" {
+		modified := ""
+		if info.Modified {
+			modified = " (modified)"
+		}
+		fmt.Fprintf(stdout, "commit: %v%v\n", info.Commit, modified)
+	}
+	if info.Date != "" {
+		fmt.Fprintf(stdout, "built: %v\n", info.Date)
+	}
+	fmt.Fprintf(stdout, "go: %v %v/%v\n", info.GoVersion, runtime.GOOS, runtime.GOARCH)
+	return nil
+}
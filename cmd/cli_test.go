@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/version"
+)
+
+// runTestCLI runs the command line against a settings file of a temporary
+// directory and returns the exit code and outputs
+func runTestCLI(t *testing.T, store *config.Store, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := runCLI(args, store, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// isolateLayers points the commands at a policy file of a temporary
+// directory, missing unless the test writes it, and at the given environment
+// rather than those of the machine running the tests. It returns the path of
+// the policy.
+func isolateLayers(t *testing.T, env map[string]string) string {
+	t.Helper()
+	saved := layers
+	t.Cleanup(func() { layers = saved })
+	layers = config.Layers{
+		PolicyPath: filepath.Join(t.TempDir(), "policy.json"),
+		LookupEnv: func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		},
+	}
+	return layers.PolicyPath
+}
+
+func TestProfileCommands(t *testing.T) {
+	store := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	isolateLayers(t, nil)
+
+	if code, _, stderr := runTestCLI(t, store, "profile", "create", "office", "-icon", "man", "-color", "system", "-paused-color", "#80808080", "-interval", "120", "-strategy", "nudge", "-schedule", "mon,fri 08:00-18:00"); code != 0 {
+		t.Fatalf("create failed with %d: %v", code, stderr)
+	}
+	if code, _, stderr := runTestCLI(t, store, "profile", "copy", "office", "presentation"); code != 0 {
+		t.Fatalf("copy failed with %d: %v", code, stderr)
+	}
+	if code, _, stderr := runTestCLI(t, store, "profile", "switch", "presentation"); code != 0 {
+		t.Fatalf("switch failed with %d: %v", code, stderr)
+	}
+
+	settings, _, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if settings.ActiveProfile != "presentation" {
+		t.Fatalf("expected presentation to be active, got %q", settings.ActiveProfile)
+	}
+	want := config.Profile{Icon: "man", Color: "", PausedColor: "#80808080", Interval: 120, Strategy: "nudge",
+		Schedule: &config.BusinessHours{Days: []string{"mon", "fri"}, From: "08:00", To: "18:00"}}
+	if !reflect.DeepEqual(settings.Current(), want) {
+		t.Fatalf("expected %+v, got %+v", want, settings.Current())
+	}
+
+	code, stdout, _ := runTestCLI(t, store, "profile", "list")
+	if code != 0 {
+		t.Fatalf("list failed with %d", code)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "* presentation") || !strings.Contains(lines[2], `color=system paused-color=#80808080 interval=120s strategy=nudge schedule="mon,fri 08:00-18:00"`) {
+		t.Fatalf("unexpected list output:\n%v", stdout)
+	}
+}
+
+func TestProfileCommandErrors(t *testing.T) {
+	store := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	isolateLayers(t, nil)
+	for _, test := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"unknown"}, 2},
+		{[]string{"profile"}, 2},
+		{[]string{"profile", "copy", "default"}, 2},
+		{[]string{"profile", "create", "office", "-speed", "2"}, 2},
+		{[]string{"profile", "create", "default"}, 1},
+		{[]string{"profile", "create", "office", "-color", "green"}, 1},
+		{[]string{"profile", "create", "office", "-error-color", "#ff00"}, 1},
+		{[]string{"profile", "create", "office", "-strategy", "teleport"}, 1},
+		{[]string{"profile", "create", "office", "-schedule", "8-18"}, 1},
+		{[]string{"profile", "switch", "missing"}, 1},
+	} {
+		code, _, stderr := runTestCLI(t, store, test.args...)
+		if code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d (%v)", test.args, test.code, code, stderr)
+		}
+		if stderr == "" {
+			t.Errorf("%v: expected an error message", test.args)
+		}
+	}
+}
+
+func TestConfigExplain(t *testing.T) {
+	store := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	policy := isolateLayers(t, map[string]string{"AMM_ICON": "cloud", "AMM_INTERVAL": "soon"})
+	if err := os.WriteFile(policy, []byte(`{"hookTimeout":20}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	code, stdout, stderr := runTestCLI(t, store, "config", "explain", "-color", "red")
+	if code != 0 {
+		t.Fatalf("explain failed with %d: %v", code, stderr)
+	}
+	for _, want := range []string{
+		`profiles.default.icon "cloud" env AMM_ICON`,
+		`profiles.default.color "red" flag -color`,
+		`hookTimeout 20 policy ` + policy,
+		`version 2 ` + store.Path(),
+		`ignored env AMM_INTERVAL: invalid value: "soon" is not a number`,
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(stdout), " "), want) {
+			t.Errorf("expected %q in:\n%v", want, stdout)
+		}
+	}
+	if _, err := os.Stat(store.Path()); !os.IsNotExist(err) {
+		t.Errorf("explain should not write the settings file")
+	}
+}
+
+func TestConfigExplainOnlyReads(t *testing.T) {
+	dir := t.TempDir()
+	store := config.NewStore(filepath.Join(dir, "settings.json"))
+	v1 := []byte(`{"version":1,"icon":"cloud","color":"red"}`)
+	if err := os.WriteFile(store.Path(), v1, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	isolateLayers(t, nil)
+
+	code, stdout, stderr := runTestCLI(t, store, "config", "explain")
+	if code != 0 {
+		t.Fatalf("explain failed with %d: %v", code, stderr)
+	}
+	if !strings.Contains(strings.Join(strings.Fields(stdout), " "), `profiles.default.icon "cloud" `+store.Path()) {
+		t.Errorf("expected the migrated icon in:\n%v", stdout)
+	}
+	if data, err := os.ReadFile(store.Path()); err != nil || !bytes.Equal(data, v1) {
+		t.Errorf("explain should not migrate the settings file, got %s (%v)", data, err)
+	}
+	if _, err := os.Stat(store.BackupPath()); !os.IsNotExist(err) {
+		t.Errorf("explain should not write a backup")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("explain should not write any file, found %v", entries)
+	}
+}
+
+func TestProfileListOnlyReads(t *testing.T) {
+	dir := t.TempDir()
+	store := config.NewStore(filepath.Join(dir, "settings.json"))
+	v1 := []byte(`{"version":1,"icon":"cloud","color":"red"}`)
+	if err := os.WriteFile(store.Path(), v1, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	isolateLayers(t, nil)
+
+	code, stdout, stderr := runTestCLI(t, store, "profile", "list")
+	if code != 0 {
+		t.Fatalf("list failed with %d: %v", code, stderr)
+	}
+	if !strings.Contains(stdout, "* default\ticon=cloud color=red") {
+		t.Errorf("expected the migrated profile in:\n%v", stdout)
+	}
+	if data, err := os.ReadFile(store.Path()); err != nil || !bytes.Equal(data, v1) {
+		t.Errorf("list should not migrate the settings file, got %s (%v)", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("list should not write any file, found %v", entries)
+	}
+}
+
+func TestProfileCommandsRespectThePolicy(t *testing.T) {
+	store := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	policy := isolateLayers(t, nil)
+	if err := os.WriteFile(policy, []byte(`{"locked":{"activeProfile":"default","profiles":{"*":{"interval":300}}}}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if code, _, stderr := runTestCLI(t, store, "profile", "create", "office", "-interval", "300"); code != 0 {
+		t.Fatalf("create with the enforced interval failed with %d: %v", code, stderr)
+	}
+	for _, args := range [][]string{
+		{"profile", "create", "home", "-interval", "60"},
+		{"profile", "switch", "office"},
+	} {
+		code, _, stderr := runTestCLI(t, store, args...)
+		if code != 1 || !strings.Contains(stderr, "locked by the system policy") {
+			t.Errorf("%v: expected to be rejected, got %d: %v", args, code, stderr)
+		}
+	}
+	settings, _, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if settings.ActiveProfile != "default" || len(settings.Profiles) != 2 {
+		t.Fatalf("rejected changes should not be saved: %+v", settings)
+	}
+}
+
+func TestConfigConvert(t *testing.T) {
+	dir := t.TempDir()
+	store := config.NewStore(filepath.Join(dir, "settings.json"))
+	isolateLayers(t, nil)
+	if err := store.Save(config.Default()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	code, stdout, stderr := runTestCLI(t, store, "config", "convert", "yaml")
+	if code != 0 {
+		t.Fatalf("convert failed with %d: %v", code, stderr)
+	}
+	yamlPath := filepath.Join(dir, "settings.yaml")
+	if !strings.Contains(stdout, yamlPath+" is now used") {
+		t.Errorf("expected the new file to be reported as used:\n%v", stdout)
+	}
+	if config.FindSettings(dir) != yamlPath {
+		t.Errorf("settings.yaml should be used after the conversion")
+	}
+	if code, _, _ := runTestCLI(t, store, "config", "convert", "yaml"); code != 1 {
+		t.Errorf("existing files should not be overwritten without -force")
+	}
+
+	tomlPath := filepath.Join(dir, "other.toml")
+	if code, _, stderr := runTestCLI(t, store, "config", "convert", yamlPath, tomlPath); code != 0 {
+		t.Fatalf("convert between files failed with %d: %v", code, stderr)
+	}
+	settings, _, err := config.NewStore(tomlPath).Load()
+	if err != nil || settings.Current() != config.DefaultProfile() {
+		t.Fatalf("unexpected converted settings %+v: %v", settings, err)
+	}
+}
+
+func TestConfigExportImport(t *testing.T) {
+	from := config.NewStore(filepath.Join(t.TempDir(), "settings.yaml"))
+	isolateLayers(t, nil)
+	settings := config.Default()
+	if err := settings.CreateProfile("office", config.Profile{Icon: "man", Color: "red", Interval: 120}); err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+	if err := from.Save(settings); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	archive := filepath.Join(t.TempDir(), "amm.zip")
+	if code, _, stderr := runTestCLI(t, from, "config", "export", archive); code != 0 {
+		t.Fatalf("export failed with %d: %v", code, stderr)
+	}
+
+	to := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	code, stdout, stderr := runTestCLI(t, to, "config", "import", "-dry-run", archive)
+	if code != 0 {
+		t.Fatalf("dry run failed with %d: %v", code, stderr)
+	}
+	if !strings.Contains(stdout, `+ profiles.office.icon = "man"`) {
+		t.Errorf("expected the new profile in the diff:\n%v", stdout)
+	}
+	if _, err := os.Stat(to.Path()); !os.IsNotExist(err) {
+		t.Fatalf("a dry run should not write anything")
+	}
+
+	if code, _, stderr := runTestCLI(t, to, "config", "import", archive); code != 0 {
+		t.Fatalf("import failed with %d: %v", code, stderr)
+	}
+	imported, _, err := to.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if imported.Profiles["office"] != settings.Profiles["office"] {
+		t.Fatalf("expected the office profile to be imported, got %+v", imported.Profiles)
+	}
+
+	if err := os.WriteFile(archive, []byte("not a zip"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if code, _, _ := runTestCLI(t, to, "config", "import", archive); code != 1 {
+		t.Errorf("damaged archives should be rejected")
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	store := config.NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	code, stdout, stderr := runTestCLI(t, store, "version")
+	if code != 0 {
+		t.Fatalf("version failed with %d: %v", code, stderr)
+	}
+	info := version.Get()
+	if !strings.HasPrefix(stdout, "amm "+info.Version+"\n") || !strings.Contains(stdout, "go: "+info.GoVersion) {
+		t.Errorf("expected the version and the Go version, got %q", stdout)
+	}
+	if code, _, _ := runTestCLI(t, store, "version", "-v"); code != 2 {
+		t.Errorf("expected a usage error for arguments, got %d", code)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxCachedIcons is the number of files and renderings kept by the cache
+const maxCachedIcons = 64
+
+// iconKey identifies an icon as read or rendered
+type iconKey struct {
+	icon  trayIcon
+	size  int //in points
+	scale int //pixels per point
+}
+
+// iconCache keeps the icons read and rendered, so that switching states
+// neither reads the files nor encodes the images again. It is cleared when
+// the icons folder or the settings change.
+type iconCache struct {
+	files    map[iconKey][]byte //by name, size and scale only
+	rendered map[iconKey][]byte
+	themes   map[string]cachedTheme
+}
+
+// cachedTheme is a theme as loaded, broken ones being kept too
+type cachedTheme struct {
+	theme icons.Theme
+	err   error
+}
+
+// cachedIcons are the icons of the tray and the menus
+var cachedIcons iconCache
+
+// file returns the icon as loaded by loadIconFile
+func (c *iconCache) file(name string, size int) []byte {
+	key := iconKey{icon: trayIcon{Name: name}, size: size, scale: iconScale}
+	if data, ok := c.files[key]; ok {
+		return data
+	}
+	data := loadIconFile(name, size)
+	c.files = store(c.files, key, data)
+	return data
+}
+
+// tray returns the icon as rendered by getTrayIcon
+func (c *iconCache) tray(icon trayIcon) []byte {
+	key := iconKey{icon: icon, size: trayIconSize, scale: iconScale}
+	if data, ok := c.rendered[key]; ok {
+		return data
+	}
+	data := renderIcon(c.file(stateIconName(icon), trayIconSize), icon)
+	c.rendered = store(c.rendered, key, data)
+	return data
+}
+
+// theme returns the theme of the icon name
+func (c *iconCache) theme(name string) (icons.Theme, error) {
+	if cached, ok := c.themes[name]; ok {
+		return cached.theme, cached.err
+	}
+	theme, err := icons.FindTheme(themeDirs(), name)
+	if c.themes == nil {
+		c.themes = make(map[string]cachedTheme)
+	}
+	c.themes[name] = cachedTheme{theme, err}
+	return theme, err
+}
+
+// clear forgets everything, e.g. when an icon file changed
+func (c *iconCache) clear() {
+	c.files, c.rendered, c.themes = nil, nil, nil
+}
+
+func store(cache map[iconKey][]byte, key iconKey, data []byte) map[iconKey][]byte {
+	if cache == nil || len(cache) >= maxCachedIcons {
+		cache = make(map[iconKey][]byte) //the icons in use are read again
+	}
+	cache[key] = data
+	return cache
+}
+
+// renderIcon renders the file of the icon in its color and state
+func renderIcon(data []byte, icon trayIcon) []byte {
+	style, render := iconStyle(icon)
+	if !render {
+		return data
+	}
+	rendered, err := icons.RenderPNG(data, style)
+	if err == nil {
+		return rendered
+	}
+	log.Errorf("Icon %v cannot be rendered, showing the mouse instead: %v", icon.Name, err)
+	mouse := loadIconFile("mouse", trayIconSize)
+	if rendered, err = icons.RenderPNG(mouse, style); err != nil {
+		return mouse
+	}
+	return rendered
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+)
+
+func TestIconCache(t *testing.T) {
+	tmpDir := setupTestIconDir(t, "mouse")
+	origScale := iconScale
+	t.Cleanup(func() { iconScale = origScale })
+
+	var cache iconCache
+	icon := trayIcon{Name: "mouse", Color: "red", Active: true}
+	file := cache.file("mouse", trayIconSize)
+	rendered := cache.tray(icon)
+	if !bytes.Equal(rendered, getTrayIcon(icon)) {
+		t.Fatalf("the cache should render like getTrayIcon")
+	}
+
+	//the files are not read again
+	for _, dir := range []string{filepath.Join(tmpDir, "assets", "icon"), filepath.Join(tmpDir, "Resources", "assets", "icon")} {
+		if err := os.Remove(filepath.Join(dir, "mouse.png")); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+	}
+	if !bytes.Equal(cache.file("mouse", trayIconSize), file) {
+		t.Errorf("the file should come from the cache")
+	}
+	if got := cache.tray(icon); &got[0] != &rendered[0] {
+		t.Errorf("the rendering should come from the cache")
+	}
+	paused := icon
+	paused.Color, paused.Badge = "white", icons.PauseBadge
+	if bytes.Equal(cache.tray(paused), rendered) {
+		t.Errorf("each state should have its own rendering")
+	}
+
+	//another scale needs the file
+	iconScale = 2
+	var panicked interface{}
+	func() {
+		defer func() { panicked = recover() }()
+		cache.tray(icon)
+	}()
+	if panicked == nil {
+		t.Errorf("the icon should be read again at another scale")
+	}
+	iconScale = origScale
+
+	cache.clear()
+	func() {
+		defer func() { panicked = recover() }()
+		cache.tray(icon)
+	}()
+	if panicked == nil {
+		t.Errorf("the icon should be read again once cleared")
+	}
+}
+
+func TestIconCacheIsBounded(t *testing.T) {
+	setupTestIconDir(t, "mouse")
+	var cache iconCache
+	for i := 0; i < 2*maxCachedIcons; i++ {
+		cache.tray(trayIcon{Name: "mouse", Color: fmt.Sprintf("#%02x0000", i), Active: true})
+	}
+	if len(cache.rendered) > maxCachedIcons {
+		t.Errorf("expected at most %d renderings, got %d", maxCachedIcons, len(cache.rendered))
+	}
+}
+
+func TestRenderIconFallsBackToTheMouse(t *testing.T) {
+	setupTestIconDir(t, "mouse")
+	icon := trayIcon{Name: "rocket.png", Color: "red", Active: true}
+	rendered := renderIcon([]byte("not a png"), icon)
+	want := renderIcon(loadIconFile("mouse", trayIconSize), icon)
+	if !bytes.Equal(rendered, want) {
+		t.Errorf("a broken icon should be replaced by the mouse")
+	}
+}
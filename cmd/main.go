@@ -1,258 +1,999 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
-	"image"
-	"image/color"
-	"image/png"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Resousse/automatic-mouse-mover/pkg/appearance"
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/hooks"
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
 	"github.com/Resousse/automatic-mouse-mover/pkg/mousemover"
+	"github.com/Resousse/automatic-mouse-mover/pkg/mqttbridge"
+	"github.com/Resousse/automatic-mouse-mover/pkg/version"
+	"github.com/Resousse/automatic-mouse-mover/pkg/webhook"
 	"github.com/getlantern/systray"
 	"github.com/go-vgo/robotgo"
 	"github.com/kirsle/configdir"
 	log "github.com/sirupsen/logrus"
 )
 
-type AppSettings struct {
-	Icon  string `json:"icon"`
-	Color string `json:"color"`
-}
-
 var configPath = configdir.LocalConfig("amm")
-var configFile = filepath.Join(configPath, "settings.json")
+var configFile = config.FindSettings(configPath)
+var hooksPath = filepath.Join(configPath, "hooks")
+var iconsPath = filepath.Join(configPath, "icons")
+
+// layers are applied on top of settings.json, the flags being parsed in main
+var layers = config.Layers{
+	PolicyPath: config.DefaultPolicyPath,
+	LookupEnv:  os.LookupEnv,
+}
 
 const alphaInactive = 0.6
 
-var (
-	colorBlue  = color.RGBA{30, 144, 255, 255}
-	colorRed   = color.RGBA{255, 0, 0, 255}
-	colorWhite = color.RGBA{255, 255, 255, 255}
+// settingsDebounce is how long settings.json must stay untouched before an
+// external edit is applied
+const settingsDebounce = 500 * time.Millisecond
+
+// schemeInterval is how often the color scheme of the desktop is checked
+// when the settings portal cannot tell its changes
+const schemeInterval = 5 * time.Second
+
+// sizes of the icons in points, drawn with iconScale pixels per point
+const (
+	trayIconSize = 22
+	menuIconSize = 16
 )
 
+// iconScale is the number of pixels per point of the display
+var iconScale = displayScale(os.Getenv, runtime.GOOS)
+
+// displayScale guesses the scale of the display: AMM_ICON_SCALE, else the
+// one GTK uses, else 2 on macOS where most displays are Retina ones
+func displayScale(getenv func(string) string, goos string) int {
+	for _, name := range []string{"AMM_ICON_SCALE", "GDK_SCALE"} {
+		if scale, err := strconv.Atoi(getenv(name)); err == nil && scale > 0 {
+			return min(scale, icons.Scales[len(icons.Scales)-1])
+		}
+	}
+	if goos == "darwin" {
+		return 2
+	}
+	return 1
+}
+
 func main() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		configdir.MakePath(configPath) //saving reports the error, if any
+		os.Exit(runCLI(os.Args[1:], config.NewStore(configFile), os.Stdout, os.Stderr))
+	}
+	flags := flag.NewFlagSet("amm", flag.ExitOnError)
+	flagValues := config.OverrideFlags(flags)
+	flags.Parse(trayArgs(os.Args[1:]))
+	layers.Flags = flagValues()
 	systray.Run(onReady, onExit)
 }
 
-func loadIconFile(iconName string) []byte {
+// trayArgs drops the process serial number older macOS versions pass to
+// apps started from the Finder
+func trayArgs(args []string) []string {
+	var kept []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-psn_") {
+			kept = append(kept, arg)
+		}
+	}
+	return kept
+}
+
+// loadIconFile returns the icon at most size points high, in pixels of the
+// display. The bundled icons are looked up as SVG or @2x/@3x PNG files too.
+func loadIconFile(iconName string, size int) []byte {
+	pixels := size * iconScale
+	if icons.IsCustom(iconName) {
+		icon, err := icons.Load(iconsPath, iconName)
+		var data []byte
+		if err == nil {
+			data, err = icons.Source{Path: icon.Path, Data: icon.Data, Scale: 1}.PNG(pixels)
+		}
+		if err == nil {
+			return data
+		}
+		log.Warnf("Icon %v unavailable, showing the mouse instead: %v", iconName, err)
+		iconName = "mouse"
+	}
+	if icons.IsTheme(iconName) {
+		data, err := loadThemeIcon(iconName, pixels)
+		if err == nil {
+			return data
+		}
+		log.Warnf("Icon %v unavailable, showing the mouse instead: %v", iconName, err)
+		iconName = "mouse"
+	}
 	if iconName != "mouse" && iconName != "cloud" && iconName != "geometric" && iconName != "man" {
+		log.Warnf("Unknown icon %v, showing the mouse instead", iconName)
 		iconName = "mouse"
 	}
-	iconPaths := []string{}
+
+	source, err := icons.FindSource(bundledIconDirs(), iconName, iconScale)
+	if err != nil {
+		panic("Failed to load icon: " + iconName + ".png")
+	}
+	data, err := source.PNG(pixels)
+	if err != nil {
+		panic("Failed to load icon: " + err.Error())
+	}
+	return data
+}
+
+// bundledIconDirs are where the icons shipped with the app are looked up
+func bundledIconDirs() []string {
+	iconDirs := []string{}
 	if base := os.Getenv("AMM_ICON_DIR"); base != "" {
-		iconPaths = append(iconPaths, filepath.Join(base, "assets", "icon", iconName+".png"))
+		iconDirs = append(iconDirs, filepath.Join(base, "assets", "icon"))
 	}
 	ex, _ := os.Executable()
 	exPath := filepath.Dir(ex)
-	iconPaths = append(iconPaths,
-		exPath+"/../Resources/assets/icon/"+iconName+".png",
-		exPath+"/../assets/icon/"+iconName+".png",
-		"./assets/icon/"+iconName+".png",
+	return append(iconDirs,
+		exPath+"/../Resources/assets/icon",
+		exPath+"/../assets/icon",
+		"./assets/icon",
 	)
+}
 
-	for _, iconPath := range iconPaths {
-		if _, err := os.Stat(iconPath); err == nil {
-			b, err := os.ReadFile(iconPath)
-			if err == nil && len(b) > 0 {
-				return b
-			}
-		}
+// themeDirs are where themes are looked up, those of the icons folder
+// first
+func themeDirs() []string {
+	return append([]string{iconsPath}, bundledIconDirs()...)
+}
+
+// loadThemeIcon returns the icon of a theme for a state, e.g.
+// theme:neon/paused, the active one if no state is given
+func loadThemeIcon(iconName string, pixels int) ([]byte, error) {
+	name, state, _ := strings.Cut(iconName, "/")
+	if state == "" {
+		state = icons.ActiveState
+	}
+	theme, err := icons.FindTheme(themeDirs(), name)
+	if err != nil {
+		return nil, err
+	}
+	source, err := theme.Source(state, iconScale)
+	if err != nil {
+		return nil, err
+	}
+	return source.PNG(pixels)
+}
+
+// stateIconName returns the name loadIconFile needs for the icon, themes
+// having an icon for each state
+func stateIconName(icon trayIcon) string {
+	if !icons.IsTheme(icon.Name) {
+		return icon.Name
+	}
+	state := icons.ActiveState
+	switch {
+	case !icon.Active:
+		state = icons.InactiveState
+	case icon.Badge == icons.ErrorBadge:
+		state = icons.ErrorState
+	case icon.Badge == icons.PauseBadge:
+		state = icons.PausedState
 	}
-	panic("Failed to load icon: " + iconName + ".png")
+	return icon.Name + "/" + state
+}
+
+// schemeColor returns the color contrasting with the color scheme of the
+// desktop, "" for the template icon when the scheme is unknown
+func schemeColor(scheme appearance.Scheme) string {
+	switch scheme {
+	case appearance.Dark:
+		return "white"
+	case appearance.Light:
+		return "#000000"
+	}
+	return ""
+}
+
+// themeTint returns the default color of the theme of the icon name, ""
+// if none
+func themeTint(iconName string) string {
+	if !icons.IsTheme(iconName) {
+		return ""
+	}
+	theme, err := cachedIcons.theme(iconName)
+	if err != nil {
+		return ""
+	}
+	return theme.Tint
 }
 
 func getMenuIcon(iconName string) []byte {
-	return loadIconFile(iconName)
+	return cachedIcons.file(iconName, menuIconSize)
 }
 
-func getTrayIcon(iconName string, active bool, col string) []byte {
-	b := loadIconFile(iconName)
+// trayIcon is what the tray shows
+type trayIcon struct {
+	Name   string
+	Color  string //"" for the system template icon
+	Blend  string
+	Active bool //dimmed when stopped
+	Badge  icons.Badge
+}
 
-	if active && col != "" {
-		img, err := png.Decode(bytes.NewReader(b))
+// iconStyle returns how to render the icon, and false if the file can be
+// shown as is
+func iconStyle(icon trayIcon) (icons.Style, bool) {
+	style := icons.Style{Badge: icon.Badge}
+	if !icon.Active {
+		style.Opacity = alphaInactive
+	} else if icon.Color != "" {
+		tint, err := icons.ParseColor(icon.Color)
 		if err != nil {
-			log.Fatalln(err)
-		}
-		var dimg *image.RGBA = image.NewRGBA(img.Bounds())
-		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-				r, g, b, a := img.At(x, y).RGBA()
-				if a != 0 {
-					switch col {
-					case "white":
-						dimg.Set(x, y, colorWhite)
-					case "red":
-						dimg.Set(x, y, colorRed)
-					default:
-						dimg.Set(x, y, colorBlue)
-					}
-				} else {
-					dimg.Set(x, y, color.RGBA{uint8(r), uint8(g), uint8(b), uint8(a)})
-				}
-			}
+			log.Warnf("Invalid color, showing blue instead: %v", err)
+			tint = icons.Named["blue"]
 		}
-		var c bytes.Buffer
-		png.Encode(&c, dimg)
-		return c.Bytes()
+		style.Color = &tint
+		if style.Mode, err = icons.ParseBlendMode(icon.Blend); err != nil {
+			log.Warnf("Invalid blend mode, tinting instead: %v", err)
+			style.Mode = icons.Tint
+		}
+	} else if icon.Badge == icons.NoBadge {
+		return style, false
 	}
+	return style, true
+}
 
-	if !active {
-		img, err := png.Decode(bytes.NewReader(b))
-		if err != nil {
-			log.Fatalln(err)
-		}
-		var dimg *image.RGBA = image.NewRGBA(img.Bounds())
-		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
-			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-				r, g, b, a := img.At(x, y).RGBA()
-				newAlpha := uint8(float64(a>>8) * alphaInactive)
-				dimg.Set(x, y, color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), newAlpha})
-			}
-		}
-		var c bytes.Buffer
-		png.Encode(&c, dimg)
-		return c.Bytes()
+// getTrayIcon renders the icon in the color, dimmed when the app is stopped,
+// with the badge of the state
+func getTrayIcon(icon trayIcon) []byte {
+	return renderIcon(loadIconFile(stateIconName(icon), trayIconSize), icon)
+}
+
+// shownIcon is the icon last shown in the tray
+var shownIcon *trayIcon
+
+func setIcon(icon trayIcon) {
+	if shownIcon != nil && *shownIcon == icon {
+		return //rendering again every second would be wasteful
 	}
+	shownIcon = &icon
+	showIconData(icon, cachedIcons.tray(forTray(icon)))
+}
 
-	return b
+// forTray drops the color of the icons shown as system template icons
+func forTray(icon trayIcon) trayIcon {
+	if !icon.Active || icon.Color == "" {
+		icon.Color, icon.Blend = "", ""
+	}
+	return icon
 }
 
-func setIcon(iconName string, color string, configFile string, settings *AppSettings, active ...bool) {
-	isActive := len(active) != 0 && active[0]
-	var iconData []byte
-	if isActive && color != "" {
-		iconData = getTrayIcon(iconName, true, color)
-		systray.SetIcon(iconData)
+// showIconData shows a rendering of the icon in the tray, as a template
+// icon if it has no color
+func showIconData(icon trayIcon, data []byte) {
+	if icon.Active && icon.Color != "" {
+		systray.SetIcon(data)
 	} else {
-		iconData = getTrayIcon(iconName, isActive, "")
-		systray.SetTemplateIcon(iconData, iconData)
+		systray.SetTemplateIcon(data, data)
 	}
-	if configFile != "" {
-		*settings = AppSettings{iconName, color}
-		fh, err := os.Create(configFile)
-		if err != nil {
-			log.Errorf("Failed to create config file: %v", err)
-			return
+}
+
+// saveSettings writes the user settings, the error being shown in the menu
+func saveSettings(settings config.AppSettings) {
+	if err := config.NewStore(configFile).Save(settings); err != nil {
+		showSettingsError(i18n.T("settings.notSaved"), err)
+	} else {
+		hideSettingsError()
+	}
+}
+
+// loadPolicy reads the system policy, nothing being enforced if it is invalid
+func loadPolicy() config.Policy {
+	policy, err := config.LoadPolicy(layers.PolicyPath)
+	if err != nil {
+		showSettingsError(i18n.T("settings.policyInvalid"), err)
+	}
+	return policy
+}
+
+// configureMover applies the profile and the limits of the policy to the
+// mouse mover, used the next time it starts
+func configureMover(m *mousemover.MouseMover, policy config.Policy, p config.Profile) {
+	m.SetHeartbeatInterval(p.IntervalSeconds())
+	m.SetStrategy(p.Strategy)
+	limits := mousemover.Limits{MaxKeepAwake: policy.MaxKeepAwake}
+	if policy.BusinessHours != nil || p.Schedule != nil {
+		limits.Allowed = func(t time.Time) bool {
+			return (policy.BusinessHours == nil || policy.BusinessHours.Allows(t)) &&
+				(p.Schedule == nil || p.Schedule.Allows(t))
 		}
-		defer fh.Close()
-		encoder := json.NewEncoder(fh)
-		encoder.Encode(settings)
 	}
+	m.SetLimits(limits)
 }
 
-func onReady() {
+// moverChanged tells if the mouse mover must restart to apply the profile
+func moverChanged(previous, current config.Profile) bool {
+	return current.IntervalSeconds() != previous.IntervalSeconds() ||
+		current.Strategy != previous.Strategy ||
+		!reflect.DeepEqual(current.Schedule, previous.Schedule)
+}
+
+// setEnabled enables or disables menu items
+func setEnabled(enabled bool, items ...*systray.MenuItem) {
+	for _, item := range items {
+		if enabled {
+			item.Enable()
+		} else {
+			item.Disable()
+		}
+	}
+}
+
+// resolve returns the effective settings: the user settings with the
+// policy, environment and command line flags applied
+func resolve(settings config.AppSettings) config.AppSettings {
+	resolved := layers.Resolve(settings, configFile)
+	for _, problem := range resolved.Problems {
+		log.Warnf("Ignored setting: %v", problem)
+	}
+	return resolved.Settings
+}
+
+// policyProfile returns a profile only defined by the policy, as it is to be
+// saved: the environment and the command line flags are left out
+func policyProfile(settings config.AppSettings, name string) config.Profile {
+	policyOnly := config.Layers{PolicyPath: layers.PolicyPath}
+	return policyOnly.Resolve(settings, configFile).Settings.Profiles[name]
+}
+
+// needsRestart tells if settings that are only read at startup changed
+func needsRestart(current, updated config.AppSettings) bool {
+	return !reflect.DeepEqual(current.MQTT, updated.MQTT) ||
+		!reflect.DeepEqual(current.Webhooks, updated.Webhooks) ||
+		current.HookTimeout != updated.HookTimeout ||
+		current.Language != updated.Language
+}
+
+// profileMenu lists the profiles in the tray, the active one being checked
+type profileMenu struct {
+	parent *systray.MenuItem
+	items  map[string]*systray.MenuItem
+	// Clicks receives the name of the profile clicked
+	Clicks chan string
+}
+
+func newProfileMenu(settings config.AppSettings) *profileMenu {
+	menu := &profileMenu{
+		parent: systray.AddMenuItem(i18n.T("menu.profile"), i18n.T("menu.profile.tooltip")),
+		items:  make(map[string]*systray.MenuItem),
+		Clicks: make(chan string),
+	}
+	menu.update(settings)
+	return menu
+}
+
+// update adds the new profiles, hides the removed ones and checks the
+// active one. Menu items cannot be removed or reordered, new profiles are
+// added at the end.
+func (menu *profileMenu) update(settings config.AppSettings) {
+	for _, name := range settings.ProfileNames() {
+		item, ok := menu.items[name]
+		if !ok {
+			item = menu.parent.AddSubMenuItemCheckbox(name, i18n.T("menu.profile.switch", name), false)
+			menu.items[name] = item
+			go func(name string) {
+				for range item.ClickedCh {
+					menu.Clicks <- name
+				}
+			}(name)
+		}
+		item.Show()
+		if name == settings.ActiveProfile {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+	for name, item := range menu.items {
+		if _, ok := settings.Profiles[name]; !ok {
+			item.Hide()
+		}
+	}
+}
+
+// setEnabled enables or disables switching profile from the menu
+func (menu *profileMenu) setEnabled(enabled bool) {
+	setEnabled(enabled, menu.parent)
+	for _, item := range menu.items {
+		setEnabled(enabled, item)
+	}
+}
+
+// iconMenu lists the bundled icons, those of the icons folder and the
+// themes, the icon of the active profile being checked.
+type iconMenu struct {
+	parent *systray.MenuItem
+	items  map[string]*systray.MenuItem
+	custom map[string]bool //custom icons and themes currently found
+	// Clicks receives the name of the icon clicked
+	Clicks chan string
+}
+
+func newIconMenu() *iconMenu {
+	menu := &iconMenu{
+		parent: systray.AddMenuItem(i18n.T("menu.icons"), i18n.T("menu.icons.tooltip")),
+		items:  make(map[string]*systray.MenuItem),
+		Clicks: make(chan string),
+	}
+	for _, name := range config.Icons {
+		menuIcon := getMenuIcon(name)
+		menu.add(name, i18n.T("icon."+name), i18n.T("menu.icons.bundled", name)).SetTemplateIcon(menuIcon, menuIcon)
+	}
+	return menu
+}
+
+func (menu *iconMenu) add(name, title, tooltip string) *systray.MenuItem {
+	item := menu.parent.AddSubMenuItemCheckbox(title, tooltip, false)
+	menu.items[name] = item
 	go func() {
-		err := configdir.MakePath(configPath)
-		if err != nil {
-			panic(err)
+		for range item.ClickedCh {
+			menu.Clicks <- name
 		}
-		var settings AppSettings
-		settings = AppSettings{"mouse", "blue"}
+	}()
+	return item
+}
 
-		if _, err = os.Stat(configFile); os.IsNotExist(err) {
-			fh, err := os.Create(configFile)
-			if err != nil {
-				panic(err)
-			}
-			defer fh.Close()
-			encoder := json.NewEncoder(fh)
-			encoder.Encode(settings)
+// scan lists the icons of the folder and the themes, adding the new ones at
+// the end and hiding the removed ones
+func (menu *iconMenu) scan() {
+	found, problems := icons.Scan(iconsPath)
+	for _, problem := range problems {
+		log.Warnf("Icon ignored: %v", problem)
+	}
+	themes, problems := icons.ScanThemes(themeDirs())
+	for _, problem := range problems {
+		log.Warnf("Theme ignored: %v", problem)
+	}
+	menu.custom = make(map[string]bool, len(found)+len(themes))
+	for _, theme := range themes {
+		name := icons.ThemeName(theme.ID)
+		menu.custom[name] = true
+		item, ok := menu.items[name]
+		if !ok {
+			item = menu.add(name, theme.Name, i18n.T("menu.icons.theme", theme.Dir))
+		}
+		if theme.Author != "" {
+			item.SetTooltip(i18n.T("menu.icons.themeBy", theme.Name, theme.Author))
+		}
+		item.SetIcon(getMenuIcon(name))
+		item.Show()
+	}
+	for _, icon := range found {
+		menu.custom[icon.Name] = true
+		item, ok := menu.items[icon.Name]
+		if !ok {
+			title := strings.TrimSuffix(icon.Name, filepath.Ext(icon.Name))
+			item = menu.add(icon.Name, title, i18n.T("menu.icons.custom", icon.Path))
+		}
+		item.SetIcon(getMenuIcon(icon.Name))
+		item.Show()
+	}
+	for name, item := range menu.items {
+		if (icons.IsCustom(name) || icons.IsTheme(name)) && !menu.custom[name] {
+			item.Hide()
+		}
+	}
+}
 
+// check marks the icon of the active profile
+func (menu *iconMenu) check(current string) {
+	for name, item := range menu.items {
+		if name == current {
+			item.Check()
 		} else {
-			fh, err := os.Open(configFile)
-			if err != nil {
-				panic(err)
-			}
-			defer fh.Close()
+			item.Uncheck()
+		}
+	}
+}
+
+// setEnabled enables or disables changing the icon from the menu
+func (menu *iconMenu) setEnabled(enabled bool) {
+	setEnabled(enabled, menu.parent)
+	for _, item := range menu.items {
+		setEnabled(enabled, item)
+	}
+}
+
+// colorTitle returns the title of a named color in the menu
+func colorTitle(name string) string {
+	if name == "" {
+		return i18n.T("color.system")
+	}
+	return i18n.T("color." + name)
+}
+
+// colorMenu lists the named colors and the presets of the settings, the
+// color of the active profile being checked
+type colorMenu struct {
+	parent *systray.MenuItem
+	items  map[string]*systray.MenuItem //by key, the color name or "preset:" and the preset name
+	values map[string]string            //color of each key
+	// Clicks receives the key of the item clicked
+	Clicks chan string
+}
+
+func newColorMenu() *colorMenu {
+	menu := &colorMenu{
+		parent: systray.AddMenuItem(i18n.T("menu.colors"), ""),
+		items:  make(map[string]*systray.MenuItem),
+		values: make(map[string]string),
+		Clicks: make(chan string),
+	}
+	for _, name := range config.Colors {
+		menu.add(name, colorTitle(name), name)
+		menu.values[name] = name
+	}
+	menu.items[""].SetTooltip(i18n.T("color.system.tooltip"))
+	return menu
+}
 
-			decoder := json.NewDecoder(fh)
-			decoder.Decode(&settings)
+func (menu *colorMenu) add(key, title, tooltip string) *systray.MenuItem {
+	item := menu.parent.AddSubMenuItemCheckbox(title, tooltip, false)
+	menu.items[key] = item
+	go func() {
+		for range item.ClickedCh {
+			menu.Clicks <- key
+		}
+	}()
+	return item
+}
+
+// update adds the new presets with a swatch of their color, and hides the
+// removed ones
+func (menu *colorMenu) update(presets []config.ColorPreset) {
+	kept := make(map[string]bool, len(presets))
+	for _, preset := range presets {
+		key := "preset:" + preset.Name
+		kept[key] = true
+		item, ok := menu.items[key]
+		if !ok {
+			item = menu.add(key, preset.Name, "")
+		}
+		if c, err := icons.ParseColor(preset.Color); err == nil {
+			item.SetIcon(icons.Swatch(c, menuIconSize*iconScale))
+		}
+		item.SetTooltip(preset.Color)
+		item.Show()
+		menu.values[key] = preset.Color
+	}
+	for key, item := range menu.items {
+		if strings.HasPrefix(key, "preset:") && !kept[key] {
+			item.Hide()
+			delete(menu.values, key)
 		}
+	}
+}
+
+// check marks the items of the active color
+func (menu *colorMenu) check(current string) {
+	for key, item := range menu.items {
+		if value, ok := menu.values[key]; ok && strings.EqualFold(value, current) {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+}
 
-		about := systray.AddMenuItem("About AMM", "Information about the app")
+// setEnabled enables or disables changing the color from the menu
+func (menu *colorMenu) setEnabled(enabled bool) {
+	setEnabled(enabled, menu.parent)
+	for _, item := range menu.items {
+		setEnabled(enabled, item)
+	}
+}
+
+// settingsWarning is shown at the top of the menu while the settings file
+// cannot be read or written
+var settingsWarning *systray.MenuItem
+
+func showSettingsError(title string, err error) {
+	log.Errorf("%v: %v", title, err)
+	if settingsWarning != nil {
+		settingsWarning.SetTitle("⚠️ " + title)
+		settingsWarning.SetTooltip(err.Error())
+		settingsWarning.Show()
+	}
+}
+
+func hideSettingsError() {
+	if settingsWarning != nil {
+		settingsWarning.Hide()
+	}
+}
+
+// loadSettings reads the settings file, creating it on first launch
+func loadSettings() config.AppSettings {
+	store := config.NewStore(configFile)
+	settings, report, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		if err := store.Save(settings); err != nil {
+			showSettingsError(i18n.T("settings.notSaved"), err)
+		}
+		return settings
+	}
+	if err != nil {
+		showSettingsError(i18n.T("settings.unreadable"), err)
+		return settings
+	}
+	if report.Recovered != "" {
+		showSettingsError(i18n.T("settings.restored"), fmt.Errorf("%v was corrupt, restored from %v", configFile, report.Recovered))
+	}
+	if report.Backup != "" {
+		log.Infof("Settings upgraded from version %v, previous file saved as %v", report.FromVersion, report.Backup)
+	}
+	for _, problem := range report.Problems {
+		log.Warnf("Ignored setting in %v: %v", configFile, problem)
+	}
+	return settings
+}
+
+func onReady() {
+	go func() {
+		i18n.SetLanguage("", os.Getenv) //the one of the system until the settings are read
+		settingsWarning = systray.AddMenuItem("", "")
+		settingsWarning.Disable()
+		settingsWarning.Hide()
+		var settings config.AppSettings
+		if err := configdir.MakePath(configPath); err != nil {
+			showSettingsError(i18n.T("settings.unavailable"), err)
+			settings = config.Default()
+		} else {
+			settings = loadSettings()
+		}
+		effective := resolve(settings)
+		i18n.SetLanguage(effective.Language, os.Getenv)
+		policy := loadPolicy()
+
+		about := systray.AddMenuItem(i18n.T("menu.about"), i18n.T("menu.about.tooltip"))
 		systray.AddSeparator()
-		ammStart := systray.AddMenuItem("Start", "start the app")
-		ammStop := systray.AddMenuItem("Stop", "stop the app")
-
-		icons := systray.AddMenuItem("Icons", "icon of the app")
-		mouse := icons.AddSubMenuItem("Mouse", "Mouse icon")
-		mouseIcon := getMenuIcon("mouse")
-		mouse.SetTemplateIcon(mouseIcon, mouseIcon)
-		cloud := icons.AddSubMenuItem("Cloud", "Cloud icon")
-		cloudIcon := getMenuIcon("cloud")
-		cloud.SetTemplateIcon(cloudIcon, cloudIcon)
-		man := icons.AddSubMenuItem("Man", "Man icon")
-		manIcon := getMenuIcon("man")
-		man.SetTemplateIcon(manIcon, manIcon)
-		geometric := icons.AddSubMenuItem("Geometric", "Geometric")
-		geometricIcon := getMenuIcon("geometric")
-		geometric.SetTemplateIcon(geometricIcon, geometricIcon)
-
-		colors := systray.AddMenuItem("Icon Colors", "")
-		system := colors.AddSubMenuItem("System", "System default color")
-		blue := colors.AddSubMenuItem("Blue 🔵", "Blue")
-		white := colors.AddSubMenuItem("White ⚪️", "White")
-		red := colors.AddSubMenuItem("Red 🔴", "Red")
+		status := newStatusMenu()
+		status.hideTitle = effective.HideTitle
+		systray.AddSeparator()
+		ammStart := systray.AddMenuItem(i18n.T("menu.start"), i18n.T("menu.start.tooltip"))
+		ammStop := systray.AddMenuItem(i18n.T("menu.stop"), i18n.T("menu.stop.tooltip"))
+		profiles := newProfileMenu(effective)
+
+		iconItems := newIconMenu()
+		iconItems.scan()
+		iconItems.check(effective.Current().Icon)
+
+		colorItems := newColorMenu()
+		colorItems.update(effective.ColorPresets)
+		colorItems.check(effective.Current().Color)
+
+		// lockMenus disables the menus of the settings locked by the policy
+		lockMenus := func() {
+			prefix := "profiles." + effective.ActiveProfile + "."
+			iconItems.setEnabled(!policy.Locks(prefix + "icon"))
+			colorItems.setEnabled(!policy.Locks(prefix + "color"))
+			profiles.setEnabled(!policy.Locks("activeProfile"))
+		}
+		lockMenus()
 
 		ammStop.Disable()
-		setIcon(settings.Icon, settings.Color, "", &settings, true)
+		setIcon(trayIcon{Name: effective.Current().Icon, Color: effective.Current().Color, Blend: effective.Current().Blend, Active: true})
+		login := newLoginMenu(defaultLauncher())
 		systray.AddSeparator()
-		mQuit := systray.AddMenuItem("Quit", "Quit the whole app")
+		mQuit := systray.AddMenuItem(i18n.T("menu.quit"), i18n.T("menu.quit.tooltip"))
 		// Sets the icon of a menu item. Only available on Mac.
 		//mQuit.SetIcon(icon.Data)
 		mouseMover := mousemover.GetInstance()
-		mouseMover.Start()
-		ammStart.Disable()
-		ammStop.Enable()
+		start := func() {
+			mouseMover.Start()
+			ammStart.Disable()
+			ammStop.Enable()
+		}
+		stop := func() {
+			ammStart.Enable()
+			ammStop.Disable()
+			mouseMover.Quit()
+		}
+		var animation moveAnimation
+		var scheme appearance.Scheme //of the desktop, for icons without color
+		// currentIcon is the icon of the active profile, in the color and
+		// with the badge of the state of the mouse mover
+		currentIcon := func() trayIcon {
+			current := effective.Current()
+			status, now := mouseMover.Status(), time.Now()
+			color := current.StateColor(now.Before(status.PausedUntil), status.DidNotMoveCount > 0)
+			if color == "" {
+				color = themeTint(current.Icon)
+			}
+			if color == "" {
+				color = schemeColor(scheme)
+			}
+			return trayIcon{
+				Name:   current.Icon,
+				Color:  color,
+				Blend:  current.Blend,
+				Active: ammStart.Disabled(),
+				Badge:  stateBadge(status, now),
+			}
+		}
+		showIcon := func() {
+			if !animation.playing() { //shown once it is over
+				setIcon(currentIcon())
+			}
+		}
+		// applyProfile shows the icon of the active profile and restarts the
+		// mouse mover if its interval, strategy or schedule changed
+		applyProfile := func(previous config.Profile) {
+			current := effective.Current()
+			iconItems.check(current.Icon)
+			colorItems.check(current.Color)
+			showIcon()
+			if !moverChanged(previous, current) {
+				return
+			}
+			configureMover(mouseMover, policy, current)
+			if ammStart.Disabled() {
+				log.Infof("restarting with an interval of %v seconds", current.IntervalSeconds())
+				mouseMover.Quit()
+				mouseMover.Start()
+			}
+		}
+		// editProfile changes the active profile from the menu
+		editProfile := func(edit func(p *config.Profile)) {
+			previous := effective.Current()
+			p, ok := settings.Profiles[effective.ActiveProfile]
+			if !ok { //only defined by the policy
+				p = policyProfile(settings, effective.ActiveProfile)
+			}
+			edit(&p)
+			updated := settings
+			updated.SetProfile(effective.ActiveProfile, p)
+			if err := policy.CheckChange(settings, updated); err != nil {
+				log.Warnf("Setting not changed: %v", err)
+				return
+			}
+			saveSettings(updated)
+			settings, effective = updated, resolve(updated)
+			applyProfile(previous)
+		}
+		configureMover(mouseMover, policy, effective.Current())
+
+		var mqttCommands <-chan mqttbridge.Command
+		if effective.MQTT != nil {
+			bridge, err := connectMQTT(*effective.MQTT, mouseMover)
+			if err != nil {
+				log.Errorf("MQTT disabled: %v", err)
+			} else {
+				defer bridge.Close()
+				mqttCommands = bridge.Commands()
+			}
+		}
+		if len(effective.Webhooks) > 0 {
+			if err := startWebhooks(effective.Webhooks, mouseMover); err != nil {
+				log.Errorf("Webhooks disabled: %v", err)
+			}
+		}
+		startHooks(time.Duration(effective.HookTimeout)*time.Second, mouseMover)
+
+		var settingsChanges <-chan config.Change
+		if watcher, err := config.NewStore(configFile).Watch(settingsDebounce); err != nil {
+			log.Errorf("Changes made to %v will need a restart: %v", configFile, err)
+		} else {
+			defer watcher.Close()
+			settingsChanges = watcher.Changes
+		}
+		var iconChanges <-chan struct{}
+		if watcher, err := icons.Watch(iconsPath, settingsDebounce); err != nil {
+			log.Errorf("Icons added to %v will need a restart: %v", iconsPath, err)
+		} else {
+			defer watcher.Close()
+			iconChanges = watcher.Changes
+		}
+		var schemeChanges <-chan appearance.Scheme
+		if detector := appearance.Default(); detector != nil {
+			watcher := appearance.Watch(detector, schemeInterval)
+			defer watcher.Close()
+			schemeChanges = watcher.Changes
+		}
+		statusEvents, _ := mouseMover.Subscribe()
+		statusTicker := time.NewTicker(statusRefresh)
+		defer statusTicker.Stop()
+		start()
 
 		for {
 			select {
+			case e := <-statusEvents:
+				status.moves.add(e)
+				status.refresh(mouseMover.Status())
+				if e.Type == event.Moved && !effective.DisableAnimation {
+					animation.play(currentIcon())
+				}
+				showIcon()
+
+			case <-animation.Ticks:
+				if !animation.step() {
+					shownIcon = nil //the last frame is shown
+					showIcon()
+				}
+
+			case <-statusTicker.C:
+				status.refresh(mouseMover.Status())
+				showIcon() //the pause may be over
+				login.refresh()
+
 			case <-ammStart.ClickedCh:
 				log.Infof("starting the app")
-				mouseMover.Start()
-				ammStart.Disable()
-				ammStop.Enable()
-				setIcon(settings.Icon, settings.Color, configFile, &settings, true)
+				start()
+				showIcon()
 
 			case <-ammStop.ClickedCh:
 				log.Infof("stopping the app")
-				ammStart.Enable()
-				ammStop.Disable()
-				mouseMover.Quit()
-				setIcon(settings.Icon, settings.Color, configFile, &settings, false)
+				stop()
+				showIcon()
+
+			case command := <-mqttCommands:
+				log.Infof("received MQTT command %v", command.Action)
+				switch command.Action {
+				case mqttbridge.Start:
+					if !ammStart.Disabled() {
+						start()
+						showIcon()
+					}
+					mouseMover.Resume()
+				case mqttbridge.Stop:
+					if !ammStop.Disabled() {
+						stop()
+						showIcon()
+					}
+				case mqttbridge.Pause:
+					mouseMover.Pause(command.Duration)
+				}
+
+			case change := <-settingsChanges:
+				if change.Err != nil {
+					showSettingsError(i18n.T("settings.invalid"), change.Err)
+					break
+				}
+				hideSettingsError()
+				for _, problem := range change.Report.Problems {
+					log.Warnf("Ignored setting in %v: %v", configFile, problem)
+				}
+				updated := resolve(change.Settings)
+				if needsRestart(effective, updated) {
+					log.Infof("MQTT, webhook, hook and language settings changed, restart to apply them")
+				}
+				previous := effective.Current()
+				settings, effective = change.Settings, updated
+				cachedIcons.clear()
+				animation.clear()
+				profiles.update(effective)
+				colorItems.update(effective.ColorPresets)
+				colorItems.check(effective.Current().Color)
+				lockMenus()
+				status.hideTitle = effective.HideTitle
+				status.refresh(mouseMover.Status())
+				if !reflect.DeepEqual(effective.Current(), previous) {
+					log.Infof("applying profile %v from %v", effective.ActiveProfile, configFile)
+					applyProfile(previous)
+				}
+
+			case name := <-profiles.Clicks:
+				previous := effective.Current()
+				updated := settings
+				if _, ok := updated.Profiles[name]; !ok { //only defined by the policy
+					updated.SetProfile(name, policyProfile(settings, name))
+				}
+				if err := updated.SwitchProfile(name); err != nil {
+					log.Errorf("Cannot switch profile: %v", err)
+					break
+				}
+				if err := policy.CheckChange(settings, updated); err != nil {
+					log.Warnf("Cannot switch profile: %v", err)
+					profiles.update(effective)
+					break
+				}
+				saveSettings(updated)
+				settings, effective = updated, resolve(updated)
+				if effective.ActiveProfile != name {
+					log.Warnf("profile %v is used, as set by the environment or the command line", effective.ActiveProfile)
+				} else {
+					log.Infof("switched to profile %v", name)
+				}
+				profiles.update(effective)
+				lockMenus()
+				applyProfile(previous)
 
 			case <-mQuit.ClickedCh:
 				log.Infof("Requesting quit")
 				mouseMover.Quit()
 				systray.Quit()
 				return
-			case <-mouse.ClickedCh:
-				setIcon("mouse", settings.Color, configFile, &settings, ammStart.Disabled())
-			case <-cloud.ClickedCh:
-				setIcon("cloud", settings.Color, configFile, &settings, ammStart.Disabled())
-			case <-man.ClickedCh:
-				setIcon("man", settings.Color, configFile, &settings, ammStart.Disabled())
-			case <-geometric.ClickedCh:
-				setIcon("geometric", settings.Color, configFile, &settings, ammStart.Disabled())
-			case <-system.ClickedCh:
-				setIcon(settings.Icon, "", configFile, &settings, ammStart.Disabled())
-			case <-blue.ClickedCh:
-				setIcon(settings.Icon, "blue", configFile, &settings, ammStart.Disabled())
-			case <-red.ClickedCh:
-				setIcon(settings.Icon, "red", configFile, &settings, ammStart.Disabled())
-			case <-white.ClickedCh:
-				setIcon(settings.Icon, "white", configFile, &settings, ammStart.Disabled())
+			case name := <-iconItems.Clicks:
+				editProfile(func(p *config.Profile) { p.Icon = name })
+				iconItems.check(effective.Current().Icon)
+			case <-iconChanges:
+				cachedIcons.clear()
+				animation.clear()
+				iconItems.scan()
+				iconItems.check(effective.Current().Icon) //a new item may be the active icon
+				lockMenus()
+				if current := effective.Current().Icon; icons.IsCustom(current) || icons.IsTheme(current) { //the files may have been replaced
+					shownIcon = nil
+					showIcon()
+				}
+			case scheme = <-schemeChanges:
+				log.Infof("Desktop color scheme: %v", scheme)
+				showIcon()
+			case <-login.Clicks:
+				login.toggle()
+			case key := <-colorItems.Clicks:
+				editProfile(func(p *config.Profile) { p.Color = colorItems.values[key] })
+				colorItems.check(effective.Current().Color)
 			case <-about.ClickedCh:
 				log.Infof("Requesting about")
-				robotgo.Alert("Automatic-mouse-mover app v1.4", "Created by Prashant Gupta. \n\nMore info at: https://github.com/resousse/automatic-mouse-mover", "OK", "")
+				build := version.Get()
+				robotgo.Alert(i18n.T("about.title", build.Version), i18n.T("about.text", "https://github.com/resousse/automatic-mouse-mover")+"\n\n"+i18n.T("about.build", build), i18n.T("about.ok"), "")
 			}
 		}
 
 	}()
 }
 
+// connectMQTT publishes the mouse mover events to the broker until the app exits
+func connectMQTT(config mqttbridge.Config, mouseMover *mousemover.MouseMover) (*mqttbridge.Bridge, error) {
+	bridge, err := mqttbridge.New(config)
+	if err != nil {
+		return nil, err
+	}
+	bridge.Connect() //in the background, not to delay the tray
+	events, _ := mouseMover.Subscribe()
+	go bridge.Run(events)
+	return bridge, nil
+}
+
+// startWebhooks delivers the mouse mover events to the configured targets
+func startWebhooks(targets []webhook.Target, mouseMover *mousemover.MouseMover) error {
+	dispatcher, err := webhook.New(targets)
+	if err != nil {
+		return err
+	}
+	events, _ := mouseMover.Subscribe()
+	go func() {
+		dispatcher.Run(events)
+		dispatcher.Close()
+	}()
+	return nil
+}
+
+// startHooks runs the user scripts of the hooks directory on mouse mover events
+func startHooks(timeout time.Duration, mouseMover *mousemover.MouseMover) {
+	if err := os.MkdirAll(hooksPath, 0o755); err != nil {
+		log.Errorf("Failed to create hooks directory: %v", err)
+	}
+	runner := hooks.NewRunner(hooksPath, timeout)
+	events, _ := mouseMover.Subscribe()
+	go func() {
+		runner.Run(events)
+		runner.Close()
+	}()
+}
+
 func onExit() {
 	// clean up here
 	log.Infof("Finished quitting")
@@ -8,6 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/appearance"
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	"github.com/Resousse/automatic-mouse-mover/pkg/mqttbridge"
 )
 
 // helper: create a 2x2 PNG with known pixels and write to path
@@ -41,6 +46,7 @@ func getRGBA8(img image.Image, x, y int) (r, g, b, a uint8) {
 // setupTestIconDir creates tmpDir with assets/icon and Resources/assets/icon containing iconName.png, sets AMM_ICON_DIR and Chdir. Caller must defer os.Chdir(origWd) and os.Unsetenv("AMM_ICON_DIR"). Returns tmpDir.
 func setupTestIconDir(t *testing.T, iconName string) string {
 	t.Helper()
+	cachedIcons.clear()
 	tmpDir := t.TempDir()
 	assetsDir := filepath.Join(tmpDir, "assets", "icon")
 	resDir := filepath.Join(tmpDir, "Resources", "assets", "icon")
@@ -89,7 +95,7 @@ func TestGetIconOriginalAndInvalidName(t *testing.T) {
 	defer os.Unsetenv("AMM_ICON_DIR")
 
 	// call getTrayIcon for valid name, inactive (alpha reduced to alphaInactive)
-	b := getTrayIcon("mouse", false, "")
+	b := getTrayIcon(trayIcon{Name: "mouse"})
 	if len(b) == 0 {
 		t.Fatalf("getTrayIcon returned empty bytes")
 	}
@@ -118,7 +124,7 @@ func TestGetIconOriginalAndInvalidName(t *testing.T) {
 	}
 
 	// calling with invalid name must default to mouse -> validate pixel
-	b2 := getTrayIcon("invalid-name", false, "")
+	b2 := getTrayIcon(trayIcon{Name: "invalid-name"})
 	img2, err := png.Decode(bytes.NewReader(b2))
 	if err != nil {
 		t.Fatalf("decode invalid-name returned bytes error: %v", err)
@@ -157,7 +163,7 @@ func TestGetIconActiveRecolors(t *testing.T) {
 	defer os.Unsetenv("AMM_ICON_DIR")
 
 	// call getTrayIcon with active=true and color "blue" -> recolor to blue
-	b := getTrayIcon("mouse", true, "blue")
+	b := getTrayIcon(trayIcon{Name: "mouse", Color: "blue", Active: true})
 	if len(b) == 0 {
 		t.Fatalf("getTrayIcon active+blue returned empty bytes")
 	}
@@ -180,7 +186,7 @@ func TestGetIconActiveRecolors(t *testing.T) {
 func TestGetTrayIconActiveNoColor(t *testing.T) {
 	setupTestIconDir(t, "mouse")
 
-	b := getTrayIcon("mouse", true, "")
+	b := getTrayIcon(trayIcon{Name: "mouse", Active: true})
 	if len(b) == 0 {
 		t.Fatalf("getTrayIcon returned empty bytes")
 	}
@@ -211,7 +217,7 @@ func TestGetTrayIconColorsRedWhite(t *testing.T) {
 	setupTestIconDir(t, "mouse")
 
 	t.Run("red", func(t *testing.T) {
-		b := getTrayIcon("mouse", true, "red")
+		b := getTrayIcon(trayIcon{Name: "mouse", Color: "red", Active: true})
 		if len(b) == 0 {
 			t.Fatalf("getTrayIcon red returned empty bytes")
 		}
@@ -230,7 +236,7 @@ func TestGetTrayIconColorsRedWhite(t *testing.T) {
 	})
 
 	t.Run("white", func(t *testing.T) {
-		b := getTrayIcon("mouse", true, "white")
+		b := getTrayIcon(trayIcon{Name: "mouse", Color: "white", Active: true})
 		if len(b) == 0 {
 			t.Fatalf("getTrayIcon white returned empty bytes")
 		}
@@ -264,7 +270,7 @@ func TestGetMenuIcon(t *testing.T) {
 		t.Fatalf("expected 2x2 image, got %dx%d", bnd.Dx(), bnd.Dy())
 	}
 	// getMenuIcon should return same as loadIconFile -> same as getTrayIcon(active, no color)
-	orig := getTrayIcon("mouse", true, "")
+	orig := getTrayIcon(trayIcon{Name: "mouse", Active: true})
 	if !bytes.Equal(menuIcon, orig) {
 		t.Fatalf("getMenuIcon should return same bytes as getTrayIcon(active, no color)")
 	}
@@ -286,7 +292,7 @@ func TestLoadIconFilePanicWhenNotFound(t *testing.T) {
 	var panicked interface{}
 	func() {
 		defer func() { panicked = recover() }()
-		getTrayIcon("mouse", true, "")
+		getTrayIcon(trayIcon{Name: "mouse", Active: true})
 	}()
 	if panicked == nil {
 		t.Fatalf("expected panic when icon file not found")
@@ -299,3 +305,168 @@ func TestLoadIconFilePanicWhenNotFound(t *testing.T) {
 		t.Fatalf("expected panic \"Failed to load icon: mouse.png\", got %q", errMsg)
 	}
 }
+
+func TestDisplayScale(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+	tests := []struct {
+		env  map[string]string
+		goos string
+		want int
+	}{
+		{nil, "linux", 1},
+		{nil, "darwin", 2},
+		{map[string]string{"GDK_SCALE": "2"}, "linux", 2},
+		{map[string]string{"GDK_SCALE": "2", "AMM_ICON_SCALE": "3"}, "linux", 3},
+		{map[string]string{"AMM_ICON_SCALE": "1"}, "darwin", 1},
+		{map[string]string{"AMM_ICON_SCALE": "8"}, "linux", 3},
+		{map[string]string{"GDK_SCALE": "x"}, "linux", 1},
+	}
+	for _, test := range tests {
+		if got := displayScale(env(test.env), test.goos); got != test.want {
+			t.Errorf("displayScale(%v, %v) = %d, want %d", test.env, test.goos, got, test.want)
+		}
+	}
+}
+
+func TestLoadIconFileAtScale(t *testing.T) {
+	tmpDir := setupTestIconDir(t, "mouse")
+	origScale := iconScale
+	t.Cleanup(func() { iconScale = origScale })
+
+	large := image.NewNRGBA(image.Rect(0, 0, 96, 96))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, large); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	assetsDir := filepath.Join(tmpDir, "assets", "icon")
+	if err := os.WriteFile(filepath.Join(assetsDir, "mouse@2x.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect width="16" height="16"/></svg>`
+	if err := os.WriteFile(filepath.Join(assetsDir, "cloud.svg"), []byte(svg), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	size := func(data []byte) int {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return img.Bounds().Dy()
+	}
+	iconScale = 1
+	if got := size(loadIconFile("mouse", trayIconSize)); got != 2 {
+		t.Errorf("mouse at 1x is %dpx high, want the 2px mouse.png", got)
+	}
+	iconScale = 2
+	if got := size(loadIconFile("mouse", trayIconSize)); got != 2*trayIconSize {
+		t.Errorf("mouse at 2x is %dpx high, want mouse@2x.png scaled to %dpx", got, 2*trayIconSize)
+	}
+	if got := size(loadIconFile("cloud", menuIconSize)); got != 2*menuIconSize {
+		t.Errorf("cloud at 2x is %dpx high, want the SVG drawn at %dpx", got, 2*menuIconSize)
+	}
+}
+
+func TestThemeIcons(t *testing.T) {
+	setupTestIconDir(t, "mouse")
+	origIconsPath, origScale := iconsPath, iconScale
+	t.Cleanup(func() { iconsPath, iconScale = origIconsPath, origScale })
+	iconsPath, iconScale = t.TempDir(), 1
+
+	theme := filepath.Join(iconsPath, "neon")
+	if err := os.MkdirAll(theme, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	//the icons are told apart by their width
+	for name, width := range map[string]int{"on": 16, "zzz": 20, "oops": 18} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, 16))); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(theme, name+".png"), buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	manifest := `{"name": "Neon", "tint": "#ff00ff", "icons": {"active": "on", "paused": "zzz", "error": "oops"}}`
+	if err := os.WriteFile(filepath.Join(theme, "theme.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	width := func(icon trayIcon) int {
+		img, err := png.Decode(bytes.NewReader(getTrayIcon(icon)))
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return img.Bounds().Dx()
+	}
+	for _, test := range []struct {
+		icon trayIcon
+		want int
+	}{
+		{trayIcon{Name: "theme:neon", Active: true}, 16},
+		{trayIcon{Name: "theme:neon", Active: true, Badge: icons.PauseBadge}, 20},
+		{trayIcon{Name: "theme:neon", Active: true, Badge: icons.ErrorBadge}, 18},
+		{trayIcon{Name: "theme:neon", Badge: icons.ErrorBadge}, 16}, //inactive
+	} {
+		if got := width(test.icon); got != test.want {
+			t.Errorf("%v is %dpx wide, want %dpx", stateIconName(test.icon), got, test.want)
+		}
+	}
+	if got := themeTint("theme:neon"); got != "#ff00ff" {
+		t.Errorf("themeTint = %q, want the tint of the manifest", got)
+	}
+	if got := width(trayIcon{Name: "theme:other", Active: true}); got != 2 {
+		t.Errorf("missing themes should show the 2px test mouse, got %dpx", got)
+	}
+}
+
+func TestSchemeColor(t *testing.T) {
+	for scheme, want := range map[appearance.Scheme]string{
+		appearance.NoPreference: "",
+		appearance.Dark:         "white",
+		appearance.Light:        "#000000",
+	} {
+		if got := schemeColor(scheme); got != want {
+			t.Errorf("schemeColor(%v) = %q, want %q", scheme, got, want)
+		}
+	}
+}
+
+func TestNeedsRestart(t *testing.T) {
+	current := config.Default()
+	updated := current
+	updated.SetCurrent(config.Profile{Icon: "cloud", Color: "red", Interval: 120})
+	if err := updated.CreateProfile("office", config.DefaultProfile()); err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+	if needsRestart(current, updated) {
+		t.Fatalf("profiles are applied live, no restart needed")
+	}
+	updated.HookTimeout = 30
+	if !needsRestart(current, updated) {
+		t.Fatalf("hook timeout is only read at startup, restart needed")
+	}
+	updated = current
+	updated.MQTT = &mqttbridge.Config{Broker: "tcp://localhost:1883"}
+	if !needsRestart(current, updated) {
+		t.Fatalf("MQTT is only read at startup, restart needed")
+	}
+}
+
+func TestPolicyProfileLeavesOutOverrides(t *testing.T) {
+	policy := isolateLayers(t, map[string]string{"AMM_PROFILE": "office", "AMM_ICON": "cloud"})
+	layers.Flags = map[string]string{"interval": "90"}
+	if err := os.WriteFile(policy, []byte(`{"profiles":{"office":{"icon":"man","interval":120}}}`), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	settings := config.Default()
+	if current := resolve(settings).Current(); current.Icon != "cloud" || current.Interval != 90 {
+		t.Fatalf("expected the overrides to apply, got %+v", current)
+	}
+	p := policyProfile(settings, "office")
+	if p.Icon != "man" || p.Interval != 120 {
+		t.Fatalf("expected the profile of the policy, got %+v", p)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	"github.com/Resousse/automatic-mouse-mover/pkg/mousemover"
+	"github.com/getlantern/systray"
+)
+
+// statusRefresh is how often the countdown and the idle duration are updated
+const statusRefresh = time.Second
+
+// moveCounter counts the movements of the current day
+type moveCounter struct {
+	day   string //"2006-01-02" the count is for
+	count int
+}
+
+// add counts the movement events
+func (c *moveCounter) add(e event.Event) {
+	if e.Type != event.Moved {
+		return
+	}
+	c.today(e.Time)
+	c.count++
+}
+
+// today returns the count for the day of now, starting over at midnight
+func (c *moveCounter) today(now time.Time) int {
+	if day := now.Format("2006-01-02"); day != c.day {
+		c.day, c.count = day, 0
+	}
+	return c.count
+}
+
+// statusLines are the titles of the status section of the menu
+func statusLines(status mousemover.Status, movesToday int, now time.Time) []string {
+	state := i18n.T("status.stopped")
+	switch {
+	case !status.Running:
+	case status.Sleeping:
+		state = i18n.T("status.sleeping")
+	case now.Before(status.PausedUntil):
+		state = i18n.T("status.pausedUntil", status.PausedUntil.Format("15:04"))
+	default:
+		state = i18n.T("status.running")
+	}
+	lastMoved := i18n.T("status.never")
+	if !status.LastMoved.IsZero() {
+		lastMoved = status.LastMoved.Format("15:04:05")
+	}
+	idle := "-"
+	if status.Running && !status.LastActivity.IsZero() {
+		idle = formatDuration(now.Sub(status.LastActivity))
+	}
+	return []string{
+		i18n.T("status.state", state),
+		i18n.T("status.lastMoved", lastMoved),
+		i18n.T("status.movesToday", movesToday),
+		i18n.T("status.failures", status.DidNotMoveCount),
+		i18n.T("status.idle", idle),
+	}
+}
+
+// trayTitle returns the text shown next to the tray icon, a countdown to the
+// next move or the end of the pause, and the tooltip telling the state
+func trayTitle(status mousemover.Status, now time.Time) (string, string) {
+	switch {
+	case !status.Running:
+		return "", i18n.T("tray.stopped")
+	case status.Sleeping:
+		return "", i18n.T("tray.sleeping")
+	case now.Before(status.PausedUntil):
+		left := formatDuration(status.PausedUntil.Sub(now))
+		return "⏸ " + left, i18n.T("tray.paused", left)
+	}
+	next := status.NextMove(now)
+	if next.IsZero() {
+		return "", i18n.T("tray.running")
+	}
+	left := formatDuration(next.Sub(now))
+	return left, i18n.T("tray.nextMove", left)
+}
+
+// stateBadge returns the badge of the state of the mouse mover, the most
+// important one if several apply
+func stateBadge(status mousemover.Status, now time.Time) icons.Badge {
+	switch {
+	case !status.Running:
+		return icons.NoBadge
+	case status.DidNotMoveCount > 0:
+		return icons.ErrorBadge
+	case status.Sleeping:
+		return icons.MoonBadge
+	case now.Before(status.PausedUntil):
+		return icons.PauseBadge
+	case status.Limited:
+		return icons.ClockBadge
+	}
+	return icons.NoBadge
+}
+
+// formatDuration shows a duration to the second, e.g. 4m 05s
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// statusMenu is the section of the menu telling what the mouse mover is
+// doing. Its items are disabled, only their titles change.
+type statusMenu struct {
+	items []*systray.MenuItem
+	moves moveCounter
+	// hideTitle only keeps the tooltip
+	hideTitle bool
+
+	titles         []string //last titles of the items, to only send changes
+	title, tooltip string
+}
+
+func newStatusMenu() *statusMenu {
+	m := &statusMenu{}
+	for _, line := range statusLines(mousemover.Status{}, 0, time.Now()) {
+		item := systray.AddMenuItem(line, "")
+		item.Disable()
+		m.items = append(m.items, item)
+	}
+	return m
+}
+
+// refresh updates the titles and the tooltip from the mouse mover
+func (m *statusMenu) refresh(status mousemover.Status) {
+	now := time.Now()
+	lines := statusLines(status, m.moves.today(now), now)
+	for i, line := range lines {
+		if m.titles == nil || m.titles[i] != line {
+			m.items[i].SetTitle(line)
+		}
+	}
+	m.titles = lines
+
+	title, tooltip := trayTitle(status, now)
+	if m.hideTitle {
+		title = ""
+	}
+	if title != m.title {
+		systray.SetTitle(title)
+		m.title = title
+	}
+	if tooltip != m.tooltip {
+		systray.SetTooltip(tooltip)
+		m.tooltip = tooltip
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	"github.com/Resousse/automatic-mouse-mover/pkg/mousemover"
+)
+
+func TestStatusLines(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	for _, test := range []struct {
+		status mousemover.Status
+		want   []string
+	}{
+		{mousemover.Status{}, []string{"State: stopped", "Last moved: never", "Moves today: 3", "Consecutive failures: 0", "Idle for: -"}},
+		{
+			mousemover.Status{Running: true, LastMoved: now.Add(-time.Minute), DidNotMoveCount: 2, LastActivity: now.Add(-245 * time.Second)},
+			[]string{"State: running", "Last moved: 14:29:00", "Moves today: 3", "Consecutive failures: 2", "Idle for: 4m 05s"},
+		},
+		{
+			mousemover.Status{Running: true, PausedUntil: now.Add(time.Hour), LastActivity: now.Add(-2 * time.Hour)},
+			[]string{"State: paused until 15:30", "Last moved: never", "Moves today: 3", "Consecutive failures: 0", "Idle for: 2h 00m"},
+		},
+		{
+			mousemover.Status{Running: true, Sleeping: true, PausedUntil: now.Add(-time.Minute)},
+			[]string{"State: system sleeping", "Last moved: never", "Moves today: 3", "Consecutive failures: 0", "Idle for: -"},
+		},
+	} {
+		if got := statusLines(test.status, 3, now); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("expected %q, got %q", test.want, got)
+		}
+	}
+}
+
+func TestMoveCounter(t *testing.T) {
+	var counter moveCounter
+	evening := time.Date(2026, 3, 2, 23, 59, 0, 0, time.Local)
+	counter.add(event.Event{Type: event.Moved, Time: evening})
+	counter.add(event.Event{Type: event.MoveFailed, Time: evening})
+	counter.add(event.Event{Type: event.Moved, Time: evening})
+	if got := counter.today(evening); got != 2 {
+		t.Fatalf("expected 2 moves, got %d", got)
+	}
+	if got := counter.today(evening.Add(2 * time.Minute)); got != 0 {
+		t.Fatalf("the count should start over at midnight, got %d", got)
+	}
+}
+
+func TestTrayTitle(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	for _, test := range []struct {
+		status         mousemover.Status
+		title, tooltip string
+	}{
+		{mousemover.Status{}, "", "AMM: stopped"},
+		{mousemover.Status{Running: true, Sleeping: true}, "", "AMM: system sleeping"},
+		{mousemover.Status{Running: true, PausedUntil: now.Add(12*time.Minute + 30*time.Second)}, "⏸ 12m 30s", "AMM: paused, resumes in 12m 30s"},
+		{mousemover.Status{Running: true, Interval: time.Minute, LastActivity: now.Add(-18 * time.Second)}, "42s", "AMM: running, next move in 42s"},
+	} {
+		title, tooltip := trayTitle(test.status, now)
+		if title != test.title || tooltip != test.tooltip {
+			t.Errorf("expected %q and %q, got %q and %q", test.title, test.tooltip, title, tooltip)
+		}
+	}
+}
+
+func TestTrayTitleTranslated(t *testing.T) {
+	i18n.SetLanguage("fr", nil)
+	t.Cleanup(func() { i18n.SetLanguage(i18n.Fallback, nil) })
+	now := time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	_, tooltip := trayTitle(mousemover.Status{Running: true, PausedUntil: now.Add(time.Minute)}, now)
+	if tooltip != "AMM : en pause, reprend dans 1m 00s" {
+		t.Errorf("expected the French tooltip, got %q", tooltip)
+	}
+	lines := statusLines(mousemover.Status{}, 2, now)
+	if lines[0] != "État : arrêté" || lines[2] != "Mouvements aujourd'hui : 2" {
+		t.Errorf("expected French status lines, got %q", lines)
+	}
+}
+
+func TestStateBadge(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		status mousemover.Status
+		want   icons.Badge
+	}{
+		{mousemover.Status{DidNotMoveCount: 1}, icons.NoBadge},
+		{mousemover.Status{Running: true}, icons.NoBadge},
+		{mousemover.Status{Running: true, Limited: true}, icons.ClockBadge},
+		{mousemover.Status{Running: true, Limited: true, PausedUntil: now.Add(time.Minute)}, icons.PauseBadge},
+		{mousemover.Status{Running: true, PausedUntil: now.Add(-time.Minute)}, icons.NoBadge},
+		{mousemover.Status{Running: true, Sleeping: true, PausedUntil: now.Add(time.Minute)}, icons.MoonBadge},
+		{mousemover.Status{Running: true, Sleeping: true, DidNotMoveCount: 3}, icons.ErrorBadge},
+	} {
+		if got := stateBadge(test.status, now); got != test.want {
+			t.Errorf("%+v: expected %q, got %q", test.status, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Package appearance tells whether the desktop uses a light or a dark color
+scheme, so that the tray icon stays visible on Linux panels where template
+icons are shown as they are.
+
+The scheme is read from the freedesktop settings portal, which most desktops
+implement, then from the GTK settings of GNOME and its derivatives. Changes
+are signalled by the portal, and polled for on desktops without it.
+*/
+package appearance
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Scheme is the color scheme of the desktop
+type Scheme int
+
+// Schemes, in the order of the portal
+const (
+	NoPreference Scheme = iota
+	Dark
+	Light
+)
+
+func (s Scheme) String() string {
+	switch s {
+	case Dark:
+		return "dark"
+	case Light:
+		return "light"
+	}
+	return "no preference"
+}
+
+// commandTimeout is how long a command reading the settings may take
+const commandTimeout = 2 * time.Second
+
+// Detector tells the color scheme of the desktop
+type Detector interface {
+	Scheme() (Scheme, error)
+}
+
+// runCommand returns the output of a command, replaced in tests
+type runCommand func(name string, args ...string) ([]byte, error)
+
+func run(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// Portal reads the color-scheme of org.freedesktop.appearance from the
+// settings portal
+type Portal struct {
+	run runCommand
+}
+
+var portalValue = regexp.MustCompile(`uint32 (\d+)`)
+
+// Scheme implements Detector
+func (p Portal) Scheme() (Scheme, error) {
+	if p.run == nil {
+		p.run = run
+	}
+	out, err := p.run("gdbus", "call", "--session",
+		"--dest", "org.freedesktop.portal.Desktop",
+		"--object-path", "/org/freedesktop/portal/desktop",
+		"--method", "org.freedesktop.portal.Settings.Read",
+		"org.freedesktop.appearance", "color-scheme")
+	if err != nil {
+		return NoPreference, err
+	}
+	match := portalValue.FindSubmatch(out) //e.g. (<<uint32 1>>,)
+	if match == nil {
+		return NoPreference, nil
+	}
+	switch string(match[1]) {
+	case "1":
+		return Dark, nil
+	case "2":
+		return Light, nil
+	}
+	return NoPreference, nil
+}
+
+// GTK reads the GTK_THEME variable, then the color-scheme and gtk-theme of
+// the GNOME interface settings
+type GTK struct {
+	run    runCommand
+	getenv func(string) string
+}
+
+// Scheme implements Detector
+func (g GTK) Scheme() (Scheme, error) {
+	if g.run == nil {
+		g.run = run
+	}
+	if g.getenv == nil {
+		g.getenv = os.Getenv
+	}
+	if theme := g.getenv("GTK_THEME"); theme != "" { //e.g. Adwaita:dark
+		return themeScheme(theme), nil
+	}
+	out, err := g.run("gsettings", "get", "org.gnome.desktop.interface", "color-scheme")
+	if err == nil {
+		switch strings.Trim(strings.TrimSpace(string(out)), "'") {
+		case "prefer-dark":
+			return Dark, nil
+		case "prefer-light":
+			return Light, nil
+		}
+	}
+	out, err = g.run("gsettings", "get", "org.gnome.desktop.interface", "gtk-theme")
+	if err != nil {
+		return NoPreference, err
+	}
+	return themeScheme(strings.Trim(strings.TrimSpace(string(out)), "'")), nil
+}
+
+// themeScheme tells the scheme of a GTK theme from its name
+func themeScheme(theme string) Scheme {
+	if theme == "" {
+		return NoPreference
+	}
+	if strings.Contains(strings.ToLower(theme), "dark") {
+		return Dark
+	}
+	return Light
+}
+
+// Chain asks each detector in turn, until one has a preference
+type Chain []Detector
+
+// Scheme implements Detector, returning the last error if none answered
+func (c Chain) Scheme() (Scheme, error) {
+	var lastErr error
+	for _, d := range c {
+		scheme, err := d.Scheme()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if scheme != NoPreference {
+			return scheme, nil
+		}
+	}
+	return NoPreference, lastErr
+}
+
+// Default returns the detector of the system, nil where template icons
+// already follow the scheme of the desktop
+func Default() Detector {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	return Chain{Portal{}, GTK{}}
+}
@@ -0,0 +1,173 @@
+package appearance
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commands fakes the output of commands, by their last argument
+func commands(outputs map[string]string) runCommand {
+	return func(name string, args ...string) ([]byte, error) {
+		out, ok := outputs[args[len(args)-1]]
+		if !ok {
+			return nil, errors.New(name + ": not found")
+		}
+		return []byte(out), nil
+	}
+}
+
+func TestPortal(t *testing.T) {
+	for out, want := range map[string]Scheme{
+		"(<<uint32 1>>,)\n": Dark,
+		"(<<uint32 2>>,)\n": Light,
+		"(<<uint32 0>>,)\n": NoPreference,
+		"(<uint32 1>,)\n":   Dark, //older portals
+		"()":                NoPreference,
+	} {
+		scheme, err := Portal{run: commands(map[string]string{"color-scheme": out})}.Scheme()
+		require.NoError(t, err)
+		assert.Equal(t, want, scheme, out)
+	}
+	_, err := Portal{run: commands(nil)}.Scheme()
+	assert.Error(t, err)
+}
+
+func TestGTK(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+	for _, test := range []struct {
+		name    string
+		env     map[string]string
+		outputs map[string]string
+		want    Scheme
+	}{
+		{"GTK_THEME", map[string]string{"GTK_THEME": "Adwaita:dark"}, map[string]string{"color-scheme": "'prefer-light'"}, Dark},
+		{"color-scheme", nil, map[string]string{"color-scheme": "'prefer-dark'\n", "gtk-theme": "'Adwaita'"}, Dark},
+		{"gtk-theme dark", nil, map[string]string{"color-scheme": "'default'", "gtk-theme": "'Yaru-dark'\n"}, Dark},
+		{"gtk-theme", nil, map[string]string{"color-scheme": "'default'", "gtk-theme": "'Yaru'"}, Light},
+		{"old GNOME", nil, map[string]string{"gtk-theme": "'Adwaita-Dark'"}, Dark},
+	} {
+		scheme, err := GTK{run: commands(test.outputs), getenv: env(test.env)}.Scheme()
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.want, scheme, test.name)
+	}
+	_, err := GTK{run: commands(nil), getenv: env(nil)}.Scheme()
+	assert.Error(t, err)
+}
+
+type fixed struct {
+	scheme Scheme
+	err    error
+}
+
+func (f fixed) Scheme() (Scheme, error) { return f.scheme, f.err }
+
+func TestChain(t *testing.T) {
+	failing := fixed{err: errors.New("no portal")}
+	scheme, err := Chain{failing, fixed{}, fixed{scheme: Light}}.Scheme()
+	require.NoError(t, err)
+	assert.Equal(t, Light, scheme)
+
+	_, err = Chain{failing, fixed{}}.Scheme()
+	assert.ErrorContains(t, err, "no portal")
+}
+
+// switching is a detector whose scheme can be changed
+type switching struct {
+	mutex  sync.Mutex
+	scheme Scheme
+}
+
+func (s *switching) Scheme() (Scheme, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.scheme, nil
+}
+
+func (s *switching) set(scheme Scheme) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scheme = scheme
+}
+
+// receiver returns functions expecting a scheme from the watcher, or none
+func receiver(t *testing.T, watcher *Watcher) (func() Scheme, func(string)) {
+	receive := func() Scheme {
+		t.Helper()
+		select {
+		case scheme := <-watcher.Changes:
+			return scheme
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected a scheme")
+			return NoPreference
+		}
+	}
+	none := func(why string) {
+		t.Helper()
+		select {
+		case scheme := <-watcher.Changes:
+			t.Fatalf("%v, got %v", why, scheme)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return receive, none
+}
+
+func TestWatch(t *testing.T) {
+	detector := &switching{scheme: Light}
+	noMonitor := func(<-chan struct{}) (<-chan string, error) {
+		return nil, errors.New("gdbus: not found")
+	}
+	watcher := watch(detector, 10*time.Millisecond, noMonitor)
+	defer watcher.Close()
+	receive, none := receiver(t, watcher)
+
+	assert.Equal(t, Light, receive(), "the first scheme is sent right away")
+	none("only changes should be sent")
+	detector.set(Dark)
+	assert.Equal(t, Dark, receive(), "the scheme is polled without the portal")
+}
+
+func TestWatchPortalSignals(t *testing.T) {
+	detector := &switching{scheme: Light}
+	lines := make(chan string)
+	monitor := func(<-chan struct{}) (<-chan string, error) {
+		return lines, nil
+	}
+	watcher := watch(detector, 10*time.Millisecond, monitor)
+	defer watcher.Close()
+	receive, none := receiver(t, watcher)
+	const unrelated = "/org/freedesktop/portal/desktop: org.freedesktop.portal.Settings.SettingChanged ('org.gnome.desktop.wm.preferences', 'theme', <'Adwaita'>)"
+
+	assert.Equal(t, Light, receive())
+	lines <- "Monitoring signals on object /org/freedesktop/portal/desktop owned by org.freedesktop.portal.Desktop"
+	lines <- "The name org.freedesktop.portal.Desktop is owned by :1.12"
+	lines <- unrelated //handled once the previous line is
+	detector.set(Dark)
+	none("the scheme should not be polled while the portal signals changes")
+	lines <- unrelated
+	none("other settings should be ignored")
+	lines <- "/org/freedesktop/portal/desktop: org.freedesktop.portal.Settings.SettingChanged ('org.freedesktop.appearance', 'color-scheme', <uint32 1>)"
+	assert.Equal(t, Dark, receive())
+
+	lines <- "The name org.freedesktop.portal.Desktop does not have an owner"
+	detector.set(Light)
+	assert.Equal(t, Light, receive(), "the scheme is polled while the portal is gone")
+
+	lines <- "The name org.freedesktop.portal.Desktop is owned by :1.13"
+	lines <- unrelated
+	close(lines)
+	detector.set(Dark)
+	assert.Equal(t, Dark, receive(), "the scheme is polled once gdbus exits")
+}
+
+func TestSchemeString(t *testing.T) {
+	assert.Equal(t, "dark", Dark.String())
+	assert.Equal(t, "no preference", NoPreference.String())
+}
@@ -0,0 +1,141 @@
+package appearance
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Watcher tells when the color scheme of the desktop changes
+type Watcher struct {
+	// Changes receives the new scheme
+	Changes <-chan Scheme
+
+	done chan struct{}
+}
+
+// monitorCommand returns the lines of a long running command, the channel
+// being closed once it exits. It stops when done is closed. Replaced in
+// tests.
+type monitorCommand func(done <-chan struct{}) (<-chan string, error)
+
+// settingChanged matches the signals of the portal about the settings the
+// detectors read, e.g.
+//
+//	/org/freedesktop/portal/desktop: org.freedesktop.portal.Settings.SettingChanged ('org.freedesktop.appearance', 'color-scheme', <uint32 1>)
+var settingChanged = regexp.MustCompile(`org\.freedesktop\.portal\.Settings\.SettingChanged \('(org\.freedesktop\.appearance|org\.gnome\.desktop\.interface)', '(color-scheme|gtk-theme)'`)
+
+// Watch sends the scheme of the detector right away, then each change. The
+// detector is asked again whenever the settings portal signals a change,
+// and every interval instead while the portal cannot be monitored.
+func Watch(d Detector, interval time.Duration) *Watcher {
+	return watch(d, interval, monitorPortal)
+}
+
+func watch(d Detector, interval time.Duration, monitor monitorCommand) *Watcher {
+	changes := make(chan Scheme)
+	w := &Watcher{Changes: changes, done: make(chan struct{})}
+	go w.run(d, interval, monitor, changes)
+	return w
+}
+
+// Close stops watching
+func (w *Watcher) Close() {
+	close(w.done)
+}
+
+func (w *Watcher) run(d Detector, interval time.Duration, monitor monitorCommand, changes chan<- Scheme) {
+	last, sent := NoPreference, false
+	// send tells the scheme if it changed, false once closed
+	send := func() bool {
+		//errors are not reported, the desktop may simply not have the
+		//tools, and the scheme is then unknown
+		scheme, _ := d.Scheme()
+		if scheme == last && sent {
+			return true
+		}
+		select {
+		case changes <- scheme:
+			last, sent = scheme, true
+			return true
+		case <-w.done:
+			return false
+		}
+	}
+	if !send() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	ticks := ticker.C //polling until the portal is known to be there
+	lines, err := monitor(w.done)
+	if err != nil {
+		lines = nil
+	}
+	for {
+		select {
+		case line, ok := <-lines:
+			switch {
+			case !ok: //gdbus is missing or lost the session bus
+				lines, ticks = nil, ticker.C
+				continue
+			case strings.HasPrefix(line, "The name ") && strings.HasSuffix(line, " does not have an owner"):
+				ticks = ticker.C
+				continue
+			case strings.HasPrefix(line, "The name ") && strings.Contains(line, " is owned by "):
+				ticks = nil //may have changed before the portal started
+			case !settingChanged.MatchString(line):
+				continue
+			}
+		case <-ticks:
+		case <-w.done:
+			return
+		}
+		if !send() {
+			return
+		}
+	}
+}
+
+// monitorPortal follows the signals of the settings portal with gdbus
+func monitorPortal(done <-chan struct{}) (<-chan string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "gdbus", "monitor", "--session",
+		"--dest", "org.freedesktop.portal.Desktop",
+		"--object-path", "/org/freedesktop/portal/desktop")
+	cmd.Env = append(os.Environ(), "LC_ALL=C") //the owner messages are translated otherwise
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer cancel()
+		scanner := bufio.NewScanner(out)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+			}
+		}
+		cmd.Wait()
+	}()
+	return lines, nil
+}
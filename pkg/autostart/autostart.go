@@ -0,0 +1,127 @@
+/*
+Package autostart starts the app with the user session.
+
+On Linux, desktops following the XDG autostart specification launch the
+.desktop files of ~/.config/autostart at login. Other platforms have no
+Launcher yet.
+*/
+package autostart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// FileName is the name of the .desktop file of the app
+const FileName = "amm.desktop"
+
+// Launcher turns starting the app at login on and off
+type Launcher interface {
+	// Enabled reads the current state from the system, which may have been
+	// changed by other tools
+	Enabled() (bool, error)
+	Enable() error
+	Disable() error
+}
+
+// XDG writes the .desktop file of the app in an autostart directory
+type XDG struct {
+	Dir  string
+	Exec string //path of the executable
+}
+
+// Default returns the launcher of the system for the executable, nil if
+// the platform has none
+func Default(exec string) Launcher {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	if appImage := os.Getenv("APPIMAGE"); appImage != "" {
+		exec = appImage //the executable is in a mount that changes each run
+	}
+	return XDG{Dir: Dir(os.Getenv), Exec: exec}
+}
+
+// Dir returns the autostart directory of the user
+func Dir(getenv func(string) string) string {
+	config := getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		config = filepath.Join(getenv("HOME"), ".config")
+	}
+	return filepath.Join(config, "autostart")
+}
+
+func (x XDG) path() string {
+	return filepath.Join(x.Dir, FileName)
+}
+
+// Enabled implements Launcher. A file hidden by the desktop settings, as
+// GNOME does when the app is disabled from its own tools, is not enabled.
+func (x XDG) Enabled() (bool, error) {
+	data, err := os.ReadFile(x.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		switch strings.TrimSpace(key) {
+		case "Hidden":
+			if strings.TrimSpace(value) == "true" {
+				return false, nil
+			}
+		case "X-GNOME-Autostart-enabled":
+			if strings.TrimSpace(value) == "false" {
+				return false, nil
+			}
+		}
+	}
+	return true, scanner.Err()
+}
+
+// Enable implements Launcher
+func (x XDG) Enable() error {
+	if err := os.MkdirAll(x.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(x.path(), []byte(x.desktopEntry()), 0o644)
+}
+
+// Disable implements Launcher
+func (x XDG) Disable() error {
+	err := os.Remove(x.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (x XDG) desktopEntry() string {
+	return `[Desktop Entry]
+Type=Application
+Name=Automatic Mouse Mover
+Comment=Keeps your machine awake by moving the mouse
+Exec=` + quoteExec(x.Exec) + `
+Terminal=false
+X-GNOME-Autostart-enabled=true
+`
+}
+
+// quoteExec quotes the path for the Exec key of desktop entries
+func quoteExec(path string) string {
+	if !strings.ContainsAny(path, " \t\"'\\`$;&|<>()*?#~=%") {
+		return path
+	}
+	//in quotes, ", `, $ and \ are escaped with a backslash, which is itself
+	//escaped in string values
+	replacer := strings.NewReplacer(`\`, `\\\\`, `"`, `\\"`, "`", "\\\\`", `$`, `\\$`, `%`, `%%`)
+	return `"` + replacer.Replace(path) + `"`
+}
@@ -0,0 +1,56 @@
+package autostart
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXDG(t *testing.T) {
+	launcher := XDG{Dir: filepath.Join(t.TempDir(), "autostart"), Exec: "/opt/amm/amm"}
+	enabled, err := launcher.Enabled()
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	require.NoError(t, launcher.Enable())
+	enabled, err = launcher.Enabled()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	data, err := os.ReadFile(filepath.Join(launcher.Dir, FileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "\nExec=/opt/amm/amm\n")
+
+	require.NoError(t, launcher.Disable())
+	enabled, err = launcher.Enabled()
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	assert.NoError(t, launcher.Disable(), "disabling twice is fine")
+}
+
+func TestXDGDisabledByTheDesktop(t *testing.T) {
+	launcher := XDG{Dir: t.TempDir(), Exec: "amm"}
+	for _, line := range []string{"Hidden=true", "X-GNOME-Autostart-enabled=false"} {
+		entry := "[Desktop Entry]\nType=Application\nExec=amm\n" + line + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(launcher.Dir, FileName), []byte(entry), 0o644))
+		enabled, err := launcher.Enabled()
+		require.NoError(t, err)
+		assert.False(t, enabled, line)
+	}
+}
+
+func TestQuoteExec(t *testing.T) {
+	assert.Equal(t, "/usr/bin/amm", quoteExec("/usr/bin/amm"))
+	assert.Equal(t, `"/home/me/My Apps/amm"`, quoteExec("/home/me/My Apps/amm"))
+	assert.Equal(t, `"/tmp/a\\$b 100%%"`, quoteExec("/tmp/a$b 100%"))
+}
+
+func TestDir(t *testing.T) {
+	env := map[string]string{"HOME": "/home/me"}
+	getenv := func(name string) string { return env[name] }
+	assert.Equal(t, "/home/me/.config/autostart", Dir(getenv))
+	env["XDG_CONFIG_HOME"] = "/cfg"
+	assert.Equal(t, "/cfg/autostart", Dir(getenv))
+}
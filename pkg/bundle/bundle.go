@@ -0,0 +1,453 @@
+/*
+Package bundle exports the whole configuration of the app to a single zip
+archive, and imports it on another machine.
+
+The archive holds the settings file, with every profile, the custom icons
+and the hook scripts, along with a manifest listing each file and its
+SHA-256:
+
+	manifest.json
+	settings.yaml
+	icons/rocket.png
+	icons/neon/theme.json
+	icons/neon/on.svg
+	hooks/on-start
+
+Importing checks the archive before anything is written: the manifest must
+match the files, the settings must be valid, icons must be PNG images of
+an acceptable size and themes must be complete. The resulting Plan tells
+what would change, and is only applied on request. Files of the machine
+that are not in the archive are left alone.
+*/
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+)
+
+// FormatVersion is the version of the archive layout written by Export
+const FormatVersion = 1
+
+// ManifestName is the name of the manifest in the archive
+const ManifestName = "manifest.json"
+
+const (
+	iconsDir    = "icons"
+	hooksDir    = "hooks"
+	maxFiles    = 200
+	maxFileSize = 10 << 20
+)
+
+// Paths are where the configuration lives on this machine
+type Paths struct {
+	Settings string //settings file, its extension giving the format
+	Icons    string //directory of the custom icons
+	Hooks    string //directory of the hook scripts
+}
+
+// Manifest describes the content of an archive
+type Manifest struct {
+	Format          int       `json:"format"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Host            string    `json:"host,omitempty"`
+	SettingsVersion int       `json:"settingsVersion"`
+	Files           []File    `json:"files"`
+}
+
+// File is an entry of the archive
+type File struct {
+	Path       string `json:"path"` //slash separated, e.g. hooks/on-start
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	Executable bool   `json:"executable,omitempty"`
+}
+
+// Action is what importing does to a file
+type Action string
+
+const (
+	Add       Action = "add"
+	Update    Action = "update"
+	Unchanged Action = "unchanged"
+)
+
+// FileChange is what importing does to an icon or a hook
+type FileChange struct {
+	Path   string
+	Action Action
+}
+
+// Plan is a checked archive, and what importing it would change
+type Plan struct {
+	Manifest Manifest
+	Settings config.AppSettings
+	// SettingsChanges are the values of the current settings that would change
+	SettingsChanges []config.Difference
+	Files           []FileChange
+
+	contents map[string][]byte
+}
+
+// Export writes the configuration to w as a zip archive
+func Export(paths Paths, w io.Writer) (Manifest, error) {
+	manifest := Manifest{
+		Format:          FormatVersion,
+		CreatedAt:       time.Now().UTC().Truncate(time.Second),
+		SettingsVersion: config.CurrentVersion,
+	}
+	manifest.Host, _ = os.Hostname()
+	contents := make(map[string][]byte)
+
+	settings, err := os.ReadFile(paths.Settings)
+	if err != nil {
+		return manifest, fmt.Errorf("cannot read the settings: %w", err)
+	}
+	name := "settings" + filepath.Ext(paths.Settings)
+	manifest.Files = append(manifest.Files, describe(name, settings, false))
+	contents[name] = settings
+
+	if err := exportFiles(paths.Icons, iconsDir, true, &manifest, contents); err != nil {
+		return manifest, err
+	}
+	if err := exportFiles(paths.Hooks, hooksDir, false, &manifest, contents); err != nil {
+		return manifest, err
+	}
+
+	archive := zip.NewWriter(w)
+	manifestJSON, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := addFile(archive, ManifestName, manifestJSON, false, manifest.CreatedAt); err != nil {
+		return manifest, err
+	}
+	for _, f := range manifest.Files {
+		if err := addFile(archive, f.Path, contents[f.Path], f.Executable, manifest.CreatedAt); err != nil {
+			return manifest, err
+		}
+	}
+	return manifest, archive.Close()
+}
+
+// exportFiles adds the files of the local directory to the archived one.
+// Subdirectories are skipped, except the theme directories when themes is
+// set, which are added along with their manifest and icons.
+func exportFiles(local, archived string, themes bool, manifest *Manifest, contents map[string][]byte) error {
+	entries, err := os.ReadDir(local)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue //editor files are not part of the configuration
+		}
+		if themes && entry.IsDir() {
+			dir := filepath.Join(local, entry.Name())
+			if _, err := os.Stat(filepath.Join(dir, icons.ManifestFile)); err != nil {
+				continue //not a theme
+			}
+			if err := exportFiles(dir, path.Join(archived, entry.Name()), false, manifest, contents); err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue //other subdirectories and links are not part of the configuration
+		}
+		data, err := os.ReadFile(filepath.Join(local, entry.Name()))
+		if err != nil {
+			return err
+		}
+		name := path.Join(archived, entry.Name())
+		manifest.Files = append(manifest.Files, describe(name, data, info.Mode().Perm()&0o111 != 0))
+		contents[name] = data
+	}
+	return nil
+}
+
+func describe(name string, data []byte, executable bool) File {
+	sum := sha256.Sum256(data)
+	return File{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:]), Executable: executable}
+}
+
+func addFile(archive *zip.Writer, name string, data []byte, executable bool, modified time.Time) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+	header.SetMode(0o644)
+	if executable {
+		header.SetMode(0o755)
+	}
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Open checks the archive at path and compares it with the configuration
+// of this machine
+func Open(archivePath string, paths Paths) (*Plan, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, info.Size(), paths)
+}
+
+// Read checks an archive and compares it with the configuration of this
+// machine
+func Read(r io.ReaderAt, size int64, paths Paths) (*Plan, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a configuration archive: %w", err)
+	}
+	if len(archive.File) > maxFiles+1 {
+		return nil, fmt.Errorf("too many files in the archive (%d)", len(archive.File))
+	}
+	contents := make(map[string][]byte)
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.UncompressedSize64 > maxFileSize {
+			return nil, fmt.Errorf("%v: file too large", f.Name)
+		}
+		data, err := readEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", f.Name, err)
+		}
+		contents[f.Name] = data
+	}
+
+	plan := &Plan{contents: contents}
+	manifestJSON, ok := contents[ManifestName]
+	if !ok {
+		return nil, errors.New("not a configuration archive: no " + ManifestName)
+	}
+	if err := json.Unmarshal(manifestJSON, &plan.Manifest); err != nil {
+		return nil, fmt.Errorf("%v: %w", ManifestName, err)
+	}
+	if plan.Manifest.Format != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format %d, expected %d", plan.Manifest.Format, FormatVersion)
+	}
+	if err := plan.check(); err != nil {
+		return nil, err
+	}
+	if err := plan.compare(paths); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, errors.New("file too large")
+	}
+	return data, nil
+}
+
+// check validates the files against the manifest, and their content
+func (p *Plan) check() error {
+	listed := map[string]bool{ManifestName: true}
+	settingsFound := false
+	themes := make(map[string]map[string][]byte) //files of each theme by name
+	for _, f := range p.Manifest.Files {
+		if listed[f.Path] {
+			return fmt.Errorf("%v is listed twice in the manifest", f.Path)
+		}
+		listed[f.Path] = true
+		data, ok := p.contents[f.Path]
+		if !ok {
+			return fmt.Errorf("%v is missing from the archive", f.Path)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != f.SHA256 || int64(len(data)) != f.Size {
+			return fmt.Errorf("%v does not match the manifest, the archive is damaged", f.Path)
+		}
+
+		dir, name := path.Split(f.Path)
+		if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("%v: invalid file name", f.Path)
+		}
+		switch dir {
+		case "":
+			if settingsFound || !strings.HasPrefix(name, "settings.") {
+				return fmt.Errorf("%v: unexpected file", f.Path)
+			}
+			settingsFound = true
+			settings, report, err := config.Parse(name, data)
+			if err != nil {
+				return fmt.Errorf("%v: %w", f.Path, err)
+			}
+			if len(report.Problems) > 0 { //importing would silently drop them
+				return fmt.Errorf("%v: %v", f.Path, report.Problems[0])
+			}
+			p.Settings = settings
+		case iconsDir + "/":
+			if err := icons.ValidateName(name); err != nil {
+				return fmt.Errorf("%v: %w", f.Path, err)
+			}
+			if err := icons.Validate(data); err != nil {
+				return fmt.Errorf("%v: %w", f.Path, err)
+			}
+		case hooksDir + "/":
+		default:
+			id, ok := themeID(dir)
+			if !ok {
+				return fmt.Errorf("%v: unexpected file", f.Path)
+			}
+			if err := checkThemeFile(name, data); err != nil {
+				return fmt.Errorf("%v: %w", f.Path, err)
+			}
+			if themes[id] == nil {
+				themes[id] = make(map[string][]byte)
+			}
+			themes[id][name] = data
+		}
+	}
+	if !settingsFound {
+		return errors.New("the archive has no settings file")
+	}
+	ids := make([]string, 0, len(themes))
+	for id := range themes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, err := icons.ParseTheme(id, themes[id]); err != nil {
+			return fmt.Errorf("%v: %w", path.Join(iconsDir, id), err)
+		}
+	}
+	for name := range p.contents {
+		if !listed[name] {
+			return fmt.Errorf("%v is not listed in the manifest", name)
+		}
+	}
+	return nil
+}
+
+// themeID returns the theme of a directory of the archive, e.g. neon for
+// icons/neon/
+func themeID(dir string) (string, bool) {
+	id, ok := strings.CutPrefix(dir, iconsDir+"/")
+	id = strings.TrimSuffix(id, "/")
+	if !ok || icons.ValidateThemeName(icons.ThemeName(id)) != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// checkThemeFile checks a file of a theme directory: the manifest, checked
+// along with the whole theme, or an icon
+func checkThemeFile(name string, data []byte) error {
+	switch {
+	case name == icons.ManifestFile:
+		return nil
+	case len(data) > icons.MaxFileSize:
+		return fmt.Errorf("file larger than %d KB", icons.MaxFileSize>>10)
+	case strings.EqualFold(filepath.Ext(name), ".png"):
+		return icons.Validate(data)
+	case strings.EqualFold(filepath.Ext(name), ".svg"):
+		return nil //checked with the theme when used by it
+	}
+	return errors.New("themes only hold " + icons.ManifestFile + ", PNG and SVG files")
+}
+
+// compare fills the changes importing would make on this machine
+func (p *Plan) compare(paths Paths) error {
+	current, _, err := config.NewStore(paths.Settings).Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot read the current settings: %w", err)
+	}
+	p.SettingsChanges = config.Diff(current, p.Settings)
+
+	for _, f := range p.Manifest.Files {
+		local := p.localPath(f, paths)
+		if local == "" {
+			continue
+		}
+		change := FileChange{Path: f.Path, Action: Add}
+		if existing, err := os.ReadFile(local); err == nil {
+			change.Action = Update
+			if bytes.Equal(existing, p.contents[f.Path]) {
+				change.Action = Unchanged
+			}
+		}
+		p.Files = append(p.Files, change)
+	}
+	sort.Slice(p.Files, func(i, j int) bool {
+		return p.Files[i].Path < p.Files[j].Path
+	})
+	return nil
+}
+
+// localPath is where an icon or a hook of the archive goes, empty for the
+// settings
+func (p *Plan) localPath(f File, paths Paths) string {
+	dir, name := path.Split(f.Path)
+	switch dir {
+	case iconsDir + "/":
+		return filepath.Join(paths.Icons, name)
+	case hooksDir + "/":
+		return filepath.Join(paths.Hooks, name)
+	}
+	if id, ok := themeID(dir); ok {
+		return filepath.Join(paths.Icons, id, name)
+	}
+	return ""
+}
+
+// Apply writes the imported configuration. The settings keep the format
+// of the current settings file.
+func (p *Plan) Apply(paths Paths) error {
+	for _, f := range p.Manifest.Files {
+		local := p.localPath(f, paths)
+		if local == "" {
+			continue
+		}
+		mode := os.FileMode(0o644)
+		if f.Executable {
+			mode = 0o755
+		}
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(local, p.contents[f.Path], mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(local, mode); err != nil { //WriteFile keeps the mode of existing files
+			return err
+		}
+	}
+	return config.NewStore(paths.Settings).Save(p.Settings)
+}
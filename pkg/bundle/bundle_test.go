@@ -0,0 +1,185 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/config"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 32, 32))))
+	return buf.Bytes()
+}
+
+// machine creates a configuration directory with the given settings
+func machine(t *testing.T, settingsName string, settings config.AppSettings) Paths {
+	t.Helper()
+	dir := t.TempDir()
+	paths := Paths{
+		Settings: filepath.Join(dir, settingsName),
+		Icons:    filepath.Join(dir, "icons"),
+		Hooks:    filepath.Join(dir, "hooks"),
+	}
+	require.NoError(t, config.NewStore(paths.Settings).Save(settings))
+	return paths
+}
+
+func export(t *testing.T, paths Paths) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	_, err := Export(paths, &buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestExportAndImport(t *testing.T) {
+	settings := config.Default()
+	require.NoError(t, settings.CreateProfile("office", config.Profile{Icon: "man", Color: "red", Interval: 120}))
+	require.NoError(t, settings.SwitchProfile("office"))
+	from := machine(t, "settings.yaml", settings)
+	require.NoError(t, os.MkdirAll(from.Icons, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(from.Icons, "rocket.png"), testPNG(t), 0o644))
+	require.NoError(t, os.MkdirAll(from.Hooks, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(from.Hooks, "on-start"), []byte("#!/bin/sh\necho started\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(from.Hooks, ".on-start.swp"), []byte("editor"), 0o644))
+	archive := export(t, from)
+
+	to := machine(t, "settings.json", config.Default())
+	require.NoError(t, os.MkdirAll(to.Hooks, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(to.Hooks, "on-start"), []byte("old"), 0o644))
+
+	plan, err := Read(bytes.NewReader(archive), int64(len(archive)), to)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, plan.Manifest.Format)
+	assert.Equal(t, []FileChange{
+		{"hooks/on-start", Update},
+		{"icons/rocket.png", Add},
+	}, plan.Files, "hidden files should not be exported")
+	assert.Equal(t, []config.Difference{
+		{Path: "activeProfile", Old: `"default"`, New: `"office"`},
+		{Path: "profiles.office.color", New: `"red"`},
+		{Path: "profiles.office.icon", New: `"man"`},
+		{Path: "profiles.office.interval", New: "120"},
+	}, plan.SettingsChanges)
+
+	data, err := os.ReadFile(filepath.Join(to.Hooks, "on-start"))
+	require.NoError(t, err)
+	assert.Equal(t, "old", string(data), "reading the archive should not change anything")
+
+	require.NoError(t, plan.Apply(to))
+	imported, _, err := config.NewStore(to.Settings).Load()
+	require.NoError(t, err)
+	assert.Equal(t, settings.Profiles, imported.Profiles)
+	assert.Equal(t, "office", imported.ActiveProfile)
+	_, err = os.Stat(filepath.Join(to.Icons, "rocket.png"))
+	assert.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(to.Hooks, "on-start"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o755), info.Mode().Perm(), "hooks should stay executable")
+	}
+
+	plan, err = Read(bytes.NewReader(archive), int64(len(archive)), to)
+	require.NoError(t, err)
+	assert.Empty(t, plan.SettingsChanges, "importing twice changes nothing")
+	assert.Equal(t, Unchanged, plan.Files[0].Action)
+}
+
+func TestExportAndImportThemes(t *testing.T) {
+	settings := config.Default()
+	settings.SetCurrent(config.Profile{Icon: "theme:neon", Color: "blue"})
+	from := machine(t, "settings.json", settings)
+	neon := filepath.Join(from.Icons, "neon")
+	require.NoError(t, os.MkdirAll(neon, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(neon, icons.ManifestFile), []byte(`{"name": "Neon", "icons": {"active": "on"}}`), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(neon, "on.png"), testPNG(t), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(from.Icons, "drafts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(from.Icons, "drafts", "sketch.png"), testPNG(t), 0o644))
+	archive := export(t, from)
+
+	to := machine(t, "settings.json", config.Default())
+	plan, err := Read(bytes.NewReader(archive), int64(len(archive)), to)
+	require.NoError(t, err)
+	assert.Equal(t, []FileChange{
+		{"icons/neon/on.png", Add},
+		{"icons/neon/theme.json", Add},
+	}, plan.Files, "only theme directories should be exported")
+
+	require.NoError(t, plan.Apply(to))
+	theme, err := icons.LoadTheme(filepath.Join(to.Icons, "neon"))
+	require.NoError(t, err)
+	assert.Equal(t, "Neon", theme.Name)
+
+	require.NoError(t, os.WriteFile(filepath.Join(neon, icons.ManifestFile), []byte(`{"name": "Neon", "icons": {"active": "off"}}`), 0o644))
+	archive = export(t, from)
+	_, err = Read(bytes.NewReader(archive), int64(len(archive)), to)
+	assert.ErrorContains(t, err, "icons/neon: theme.json: icons: active: no file off.svg or off.png")
+
+	require.NoError(t, os.WriteFile(filepath.Join(neon, "notes.txt"), []byte("todo"), 0o644))
+	archive = export(t, from)
+	_, err = Read(bytes.NewReader(archive), int64(len(archive)), to)
+	assert.ErrorContains(t, err, "icons/neon/notes.txt")
+}
+
+// rewrite copies the archive, changing or adding files
+func rewrite(t *testing.T, archive []byte, changes map[string][]byte) []byte {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, f := range r.File {
+		data, err := readEntry(f)
+		require.NoError(t, err)
+		if changed, ok := changes[f.Name]; ok {
+			data = changed
+			delete(changes, f.Name)
+		}
+		require.NoError(t, addFile(w, f.Name, data, false, f.Modified))
+	}
+	for name, data := range changes {
+		require.NoError(t, addFile(w, name, data, false, r.File[0].Modified))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestImportValidation(t *testing.T) {
+	from := machine(t, "settings.json", config.Default())
+	require.NoError(t, os.MkdirAll(from.Icons, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(from.Icons, "rocket.png"), testPNG(t), 0o644))
+	archive := export(t, from)
+	to := machine(t, "settings.json", config.Default())
+
+	for name, changes := range map[string]map[string][]byte{
+		"damaged file":   {"icons/rocket.png": []byte("not a png")},
+		"unlisted file":  {"hooks/on-start": []byte("#!/bin/sh\n")},
+		"path traversal": {"../settings.json": []byte("{}")},
+		"nested theme":   {"icons/neon/../../settings.json": []byte("{}")},
+		"no manifest":    {ManifestName: []byte("{")},
+	} {
+		broken := rewrite(t, archive, changes)
+		_, err := Read(bytes.NewReader(broken), int64(len(broken)), to)
+		assert.Error(t, err, name)
+	}
+
+	_, err := Read(bytes.NewReader([]byte("plain text")), 10, to)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(from.Settings, []byte(`{"version":2,"hookTimeout":-1}`), 0o644))
+	archive = export(t, from)
+	_, err = Read(bytes.NewReader(archive), int64(len(archive)), to)
+	assert.ErrorContains(t, err, "hookTimeout", "invalid settings should not be imported")
+}
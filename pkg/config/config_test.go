@@ -0,0 +1,165 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func readJSON(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &out))
+	return out
+}
+
+func TestLoadMigratesUnversionedFile(t *testing.T) {
+	original := `{"icon":"rocket","color":"green"}` + "\n"
+	path := writeSettings(t, original)
+
+	s, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.FromVersion)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, CurrentVersion, s.Version)
+	assert.Equal(t, DefaultProfileName, s.ActiveProfile)
+	assert.Equal(t, "mouse", s.Current().Icon, "unknown icons were displayed as the mouse")
+	assert.Equal(t, "blue", s.Current().Color, "unknown colors were displayed in blue")
+
+	require.Equal(t, path+".v0.bak", report.Backup)
+	backup, err := os.ReadFile(report.Backup)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(backup), "backup should be the original file")
+
+	saved := readJSON(t, path)
+	assert.EqualValues(t, CurrentVersion, saved["version"], "migrated file should be written back")
+	assert.NotContains(t, saved, "icon", "icon moved to the profile")
+
+	_, report, err = NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Backup, "current files should not be migrated again")
+}
+
+func TestLoadMigratesVersion1File(t *testing.T) {
+	path := writeSettings(t, `{"version":1,"icon":"cloud","color":"","hookTimeout":5}`)
+	s, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, path+".v1.bak", report.Backup)
+	assert.Equal(t, map[string]Profile{
+		DefaultProfileName: {Icon: "cloud", Color: ""},
+	}, s.Profiles, "system color should survive the migration")
+	assert.Equal(t, 5, s.HookTimeout)
+}
+
+func TestUnknownAndInvalidFieldsAreReportedAndPreserved(t *testing.T) {
+	path := writeSettings(t, `{"version":2,"activeProfile":"home","profiles":{"home":{"icon":"man","color":"purple"}},"hookTimeout":"soon","webhooks":[],"futureOption":{"a":1}}`)
+
+	s, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Backup)
+	assert.Equal(t, []Problem{
+		{"activeProfile", `invalid value: no profile named "home"`},
+		{"futureOption", "unknown field"},
+		{"hookTimeout", "invalid value: json: cannot unmarshal string into Go value of type int"},
+		{"profiles.home", `invalid value: color: "purple" is not blue, white, red or a #RRGGBB or #RRGGBBAA value`},
+	}, report.Problems)
+	assert.Equal(t, Default().Profiles, s.Profiles, "no valid profile left, the default is used")
+	assert.Equal(t, DefaultProfileName, s.ActiveProfile, "invalid fields use the default")
+	assert.Equal(t, 0, s.HookTimeout, "invalid fields use the default")
+
+	require.NoError(t, NewStore(path).Save(s))
+	saved := readJSON(t, path)
+	assert.Equal(t, "home", saved["activeProfile"], "invalid value should be written back")
+	assert.Equal(t, "soon", saved["hookTimeout"], "invalid value should be written back")
+	assert.Equal(t, "purple", saved["profiles"].(map[string]interface{})["home"].(map[string]interface{})["color"], "invalid value should be written back")
+	assert.Equal(t, map[string]interface{}{"a": 1.0}, saved["futureOption"], "unknown field should be written back")
+
+	s.HookTimeout = 30
+	require.NoError(t, NewStore(path).Save(s))
+	saved = readJSON(t, path)
+	assert.EqualValues(t, 30, saved["hookTimeout"], "a value changed by the app replaces the invalid one")
+	assert.Equal(t, "home", saved["activeProfile"])
+}
+
+func TestInvalidProfileDoesNotDropTheOthers(t *testing.T) {
+	path := writeSettings(t, `{"version":2,"activeProfile":"home","profiles":{"home":{"icon":"man","color":"red","interval":30},"office":{"icon":"mouse","color":"blue"}}}`)
+
+	s, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 2)
+	assert.Equal(t, "profiles.home", report.Problems[1].Field)
+	assert.Equal(t, []string{"office"}, s.ProfileNames(), "only the invalid profile is skipped")
+	assert.Equal(t, "office", s.ActiveProfile)
+
+	s.SetProfile("office", Profile{Icon: "mouse", Color: "white"})
+	require.NoError(t, NewStore(path).Save(s))
+	saved := readJSON(t, path)
+	profiles := saved["profiles"].(map[string]interface{})
+	assert.Equal(t, "white", profiles["office"].(map[string]interface{})["color"], "the edit should be saved")
+	assert.Equal(t, map[string]interface{}{"icon": "man", "color": "red", "interval": 30.0}, profiles["home"], "the invalid profile should be written back unchanged")
+	assert.Equal(t, "home", saved["activeProfile"], "the active profile should be written back")
+
+	s, report, err = NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Len(t, report.Problems, 2, "the invalid profile is still reported, not lost")
+	assert.Equal(t, "white", s.Profiles["office"].Color)
+}
+
+func TestMissingProfileFieldsUseDefaults(t *testing.T) {
+	s, problems, err := Decode([]byte(`{"version":2,"profiles":{"office":{"interval":120}}}`))
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, "office", s.ActiveProfile, "an existing profile should be activated")
+	assert.Equal(t, Profile{Icon: "mouse", Color: "blue", Interval: 120}, s.Current())
+}
+
+func TestNewerVersionIsNotDowngraded(t *testing.T) {
+	path := writeSettings(t, `{"version":99,"activeProfile":"default","profiles":{"default":{"icon":"man","color":"red"}}}`)
+	s, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Backup)
+	assert.Equal(t, []Problem{{"version", "written by a newer version of the app (99)"}}, report.Problems)
+	assert.Equal(t, "man", s.Current().Icon)
+
+	require.NoError(t, NewStore(path).Save(s))
+	assert.EqualValues(t, 99, readJSON(t, path)["version"])
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, _, err := NewStore(filepath.Join(t.TempDir(), "missing.json")).Load()
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	s, _, err := NewStore(writeSettings(t, "")).Load()
+	assert.Error(t, err, "empty file should be an error")
+	assert.Equal(t, Default(), s)
+
+	_, _, err = NewStore(writeSettings(t, `{"version":"one"}`)).Load()
+	assert.Error(t, err, "invalid version should be an error")
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	s := Default()
+	s.SetCurrent(Profile{Icon: "geometric", Color: "", Interval: 90})
+	s.HookTimeout = 3
+	require.NoError(t, NewStore(path).Save(s))
+
+	loaded, report, err := NewStore(path).Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, s, loaded)
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Difference is a value that differs between two settings
+type Difference struct {
+	Path string
+	Old  string //JSON, empty when missing
+	New  string //JSON, empty when missing
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Old == "":
+		return "+ " + d.Path + " = " + d.New
+	case d.New == "":
+		return "- " + d.Path + " = " + d.Old
+	}
+	return "~ " + d.Path + " = " + d.Old + " -> " + d.New
+}
+
+// Diff lists the values that differ, sorted by path. Secrets are not shown.
+func Diff(before, after AppSettings) []Difference {
+	paths, oldValues, newValues := diff(before, after)
+	changes := make([]Difference, len(paths))
+	for i, path := range paths {
+		key := path[strings.LastIndex(path, ".")+1:]
+		changes[i] = Difference{path, changeJSON(key, oldValues, path), changeJSON(key, newValues, path)}
+	}
+	return changes
+}
+
+func changeJSON(key string, values map[string]interface{}, path string) string {
+	value, ok := values[path]
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(redact(key, value))
+	return string(data)
+}
+
+// diff returns the paths of the values that differ, and the values of both
+// settings by path
+func diff(before, after AppSettings) ([]string, map[string]interface{}, map[string]interface{}) {
+	oldValues, newValues := leaves(before), leaves(after)
+	var paths []string
+	for path, value := range oldValues {
+		if newValue, ok := newValues[path]; !ok || !reflect.DeepEqual(value, newValue) {
+			paths = append(paths, path)
+		}
+	}
+	for path := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, oldValues, newValues
+}
+
+// leaves maps the path of every value of the settings to the value
+func leaves(s AppSettings) map[string]interface{} {
+	type plain AppSettings //without the preserved fields
+	data, _ := json.Marshal(plain(s))
+	t, _ := decodeTree(data)
+	values := make(map[string]interface{})
+	t.walk(nil, func(path []string, value interface{}) {
+		values[strings.Join(path, ".")] = value
+	})
+	return values
+}
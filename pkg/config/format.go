@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a syntax of the settings file, given by its extension. Every
+// format has the same schema as settings.json.
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// settingsFiles are the names of the settings file, by precedence: a file
+// written by hand wins over the settings.json created on first launch
+var settingsFiles = []string{"settings.yaml", "settings.yml", "settings.toml", "settings.json"}
+
+// FindSettings returns the settings file of the directory, settings.json if
+// there is none yet
+func FindSettings(dir string) string {
+	for _, name := range settingsFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, "settings.json")
+}
+
+// FormatOf returns the format of a settings file from its extension
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("%v: unknown settings format, expected .json, .yaml or .toml", path)
+}
+
+// toJSON converts the content of a settings file to JSON
+func toJSON(format Format, data []byte) ([]byte, error) {
+	var v interface{}
+	switch format {
+	case YAML:
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+	case TOML:
+		var table map[string]interface{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, err
+		}
+		v = table
+	default:
+		return data, nil
+	}
+	return json.Marshal(v)
+}
+
+// fromJSON converts settings to the format. The comments of the previous
+// YAML file are kept for the fields that still exist; TOML files are
+// written without comments.
+func fromJSON(format Format, data, previous []byte) ([]byte, error) {
+	switch format {
+	case YAML:
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		blockStyle(&doc)
+		var old yaml.Node
+		if yaml.Unmarshal(previous, &old) == nil {
+			keepComments(&doc, &old)
+		}
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, err
+		}
+		encoder.Close()
+		return buf.Bytes(), nil
+	case TOML:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(forTOML(v)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
+// blockStyle turns the JSON syntax parsed as YAML into the usual YAML syntax
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && strings.ContainsAny(node.Value, "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// keepComments copies the comments of old to the same keys and items of node
+func keepComments(node, old *yaml.Node) {
+	if node.Kind != old.Kind {
+		return
+	}
+	node.HeadComment, node.LineComment, node.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for i := 0; i < len(node.Content) && i < len(old.Content); i++ {
+			keepComments(node.Content[i], old.Content[i])
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			for j := 0; j+1 < len(old.Content); j += 2 {
+				if node.Content[i].Value == old.Content[j].Value {
+					keepComments(node.Content[i], old.Content[j])
+					keepComments(node.Content[i+1], old.Content[j+1])
+					break
+				}
+			}
+		}
+	}
+}
+
+// forTOML converts decoded JSON to values TOML can encode: integers stay
+// integers, and null values are left out since TOML has none
+func forTOML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		table := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if value != nil {
+				table[key] = forTOML(value)
+			}
+		}
+		return table
+	case []interface{}:
+		array := make([]interface{}, 0, len(v))
+		for _, value := range v {
+			if value != nil {
+				array = append(array, forTOML(value))
+			}
+		}
+		return array
+	}
+	return v
+}
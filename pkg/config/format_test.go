@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const yamlSettings = `# my settings
+version: 2
+activeProfile: office # used at work
+profiles:
+  default:
+    icon: mouse
+    color: blue
+  # for the office
+  office:
+    icon: man
+    color: ""
+    interval: 120
+hookTimeout: 5
+`
+
+func TestYAMLSettingsKeepTheirComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(yamlSettings), 0o644))
+
+	store := NewStore(path)
+	s, report, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+	assert.Equal(t, Profile{Icon: "man", Color: "", Interval: 120}, s.Current())
+	assert.Equal(t, 5, s.HookTimeout)
+
+	s.SetCurrent(Profile{Icon: "cloud", Color: "red", Interval: 120})
+	require.NoError(t, store.Save(s))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# my settings
+version: 2
+activeProfile: office # used at work
+profiles:
+  default:
+    icon: mouse
+    color: blue
+  # for the office
+  office:
+    icon: cloud
+    color: red
+    interval: 120
+hookTimeout: 5
+`, string(data))
+
+	loaded, _, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, s.Profiles, loaded.Profiles)
+}
+
+func TestTOMLSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.toml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+# migrated from version 0
+icon = "cloud"
+color = "white"
+
+[[webhooks]]
+url = "https://example.com/hook"
+events = ["start", "stop"]
+`), 0o644))
+
+	store := NewStore(path)
+	s, report, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, path+".v0.bak", report.Backup, "TOML files are migrated like JSON ones")
+	assert.Equal(t, Profile{Icon: "cloud", Color: "white"}, s.Current())
+	require.Len(t, s.Webhooks, 1)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "version = 2\n", "integers should stay integers")
+	assert.Contains(t, string(data), "[profiles.default]\n")
+
+	loaded, _, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, s.Profiles, loaded.Profiles)
+	assert.Equal(t, s.Webhooks, loaded.Webhooks)
+}
+
+func TestInvalidYAMLIsCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("profiles: [\n"), 0o644))
+	_, _, err := NewStore(path).Load()
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	from := filepath.Join(dir, "settings.yaml")
+	require.NoError(t, os.WriteFile(from, []byte(yamlSettings), 0o644))
+
+	for _, to := range []string{"settings.toml", "settings.json"} {
+		_, err := Convert(from, filepath.Join(dir, to))
+		require.NoError(t, err)
+		s, report, err := NewStore(filepath.Join(dir, to)).Load()
+		require.NoError(t, err)
+		assert.Empty(t, report.Problems)
+		assert.Equal(t, "office", s.ActiveProfile, to)
+		assert.Equal(t, 120, s.Current().Interval, to)
+		from = filepath.Join(dir, to)
+	}
+	_, err := Convert(from, filepath.Join(dir, "settings.ini"))
+	assert.Error(t, err)
+}
+
+func TestFindSettings(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, filepath.Join(dir, "settings.json"), FindSettings(dir))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "settings.json"), []byte("{}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "settings.toml"), []byte(""), 0o644))
+	assert.Equal(t, filepath.Join(dir, "settings.toml"), FindSettings(dir), "hand written files win")
+}
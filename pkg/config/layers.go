@@ -0,0 +1,308 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPolicyPath is the system wide policy file, set by administrators
+var DefaultPolicyPath = defaultPolicyPath()
+
+func defaultPolicyPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "amm", "policy.json")
+	}
+	return "/etc/amm/policy.json"
+}
+
+// SourceDefault is the source of the values nobody set
+const SourceDefault = "default"
+
+// Override is a setting that can also be given by an environment variable
+// or a command line flag
+type Override struct {
+	// Path of the setting in settings.json, relative to the active profile
+	// when Profile is set
+	Path    string
+	Profile bool
+	Env     string
+	Flag    string
+	Int     bool
+	Usage   string
+}
+
+// Overrides are the settings available from the environment and the command
+// line. The active profile is resolved first, so that the profile values
+// apply to the profile it selects.
+var Overrides = []Override{
+	{Path: "activeProfile", Env: "AMM_PROFILE", Flag: "profile", Usage: "name of the profile to use"},
+	{Path: "icon", Profile: true, Env: "AMM_ICON", Flag: "icon", Usage: "icon of the tray"},
+	{Path: "color", Profile: true, Env: "AMM_COLOR", Flag: "color", Usage: "color of the icon"},
+	{Path: "interval", Profile: true, Env: "AMM_INTERVAL", Flag: "interval", Int: true, Usage: "seconds without activity before moving"},
+	{Path: "hookTimeout", Env: "AMM_HOOK_TIMEOUT", Flag: "hook-timeout", Int: true, Usage: "seconds a hook script may run"},
+	{Path: "mqtt.broker", Env: "AMM_MQTT_BROKER", Flag: "mqtt-broker", Usage: "MQTT broker, e.g. tcp://homeassistant.local:1883"},
+	{Path: "mqtt.username", Env: "AMM_MQTT_USERNAME", Flag: "mqtt-username", Usage: "MQTT user name"},
+	{Path: "mqtt.password", Env: "AMM_MQTT_PASSWORD", Usage: "MQTT password"},
+}
+
+// OverrideFlags adds the overrides to the flag set. The returned function
+// gives the values of the flags set on the command line, by flag name.
+func OverrideFlags(flags *flag.FlagSet) func() map[string]string {
+	for _, o := range Overrides {
+		if o.Flag != "" {
+			flags.String(o.Flag, "", o.Usage)
+		}
+	}
+	return func() map[string]string {
+		values := make(map[string]string)
+		flags.Visit(func(f *flag.Flag) {
+			values[f.Name] = f.Value.String()
+		})
+		return values
+	}
+}
+
+// Layers are the sources of the settings, by increasing precedence: the
+// built-in defaults, the policy file, the user settings, the environment
+// and the command line flags. The settings locked by the policy win over
+// all of them.
+type Layers struct {
+	PolicyPath string                          //no policy when empty
+	LookupEnv  func(key string) (string, bool) //no environment when nil
+	Flags      map[string]string               //values by flag name
+}
+
+// Resolved are the effective settings, along with where each value came from
+type Resolved struct {
+	Settings AppSettings
+	// Sources maps the path of each value set by a layer, e.g.
+	// "profiles.default.icon", to the name of that layer
+	Sources  map[string]string
+	Problems []Problem
+	Policy   Policy
+}
+
+// Value is an effective setting, as explained to the user
+type Value struct {
+	Path   string
+	Value  string //JSON
+	Source string
+}
+
+// tree is a settings file decoded without a schema
+type tree map[string]interface{}
+
+// Resolve applies the layers on top of the user settings. Values that
+// cannot be used are reported and ignored, the policy file being optional.
+// An invalid policy is reported and nothing of it is applied.
+func (l Layers) Resolve(user AppSettings, userSource string) Resolved {
+	r := Resolved{Sources: make(map[string]string)}
+	merged := tree{}
+
+	if l.PolicyPath != "" {
+		policy, err := LoadPolicy(l.PolicyPath)
+		if err != nil {
+			r.Problems = append(r.Problems, Problem{l.PolicyPath, err.Error()})
+		}
+		r.Policy = policy
+		merged.merge(policy.defaults, nil, "policy "+l.PolicyPath, r.Sources)
+	}
+
+	type plain AppSettings //without the preserved fields, already reported
+	data, _ := json.Marshal(plain(user))
+	userTree, _ := decodeTree(data)
+	merged.merge(userTree, nil, userSource, r.Sources)
+
+	//the profile overrides depend on the active profile
+	for _, profileValues := range []bool{false, true} {
+		active, _ := merged["activeProfile"].(string)
+		for _, o := range Overrides {
+			if o.Profile != profileValues {
+				continue
+			}
+			value, source, ok := l.lookup(o)
+			if !ok {
+				continue
+			}
+			raw, err := o.parse(value)
+			if err != nil {
+				r.Problems = append(r.Problems, Problem{source, "invalid value: " + err.Error()})
+				continue
+			}
+			path := strings.Split(o.Path, ".")
+			if o.Profile {
+				path = append([]string{"profiles", active}, path...)
+			}
+			merged.set(path, raw, source, r.Sources)
+		}
+	}
+
+	merged.enforce(r.Policy.locked, nil, "policy "+l.PolicyPath+" (locked)", r.Sources)
+
+	data, _ = json.Marshal(merged)
+	var raw map[string]json.RawMessage
+	json.Unmarshal(data, &raw)
+	settings, problems, _ := decodeFields(raw)
+	for _, problem := range problems {
+		record(r.Sources, []string{problem.Field}, SourceDefault) //the invalid value was not used
+	}
+	r.Settings = settings
+	r.Problems = append(r.Problems, problems...)
+	return r
+}
+
+// lookup returns the value of an override, the flag winning over the
+// environment variable
+func (l Layers) lookup(o Override) (value, source string, ok bool) {
+	if value, ok := l.Flags[o.Flag]; ok && o.Flag != "" {
+		return value, "flag -" + o.Flag, true
+	}
+	if l.LookupEnv != nil && o.Env != "" {
+		if value, ok := l.LookupEnv(o.Env); ok {
+			return value, "env " + o.Env, true
+		}
+	}
+	return "", "", false
+}
+
+func (o Override) parse(value string) (interface{}, error) {
+	if o.Int {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return json.Number(strconv.Itoa(n)), nil
+	}
+	return value, nil
+}
+
+// Explain lists the effective values and their source, sorted by path
+func (r Resolved) Explain() []Value {
+	type plain AppSettings
+	data, _ := json.Marshal(plain(r.Settings))
+	effective, _ := decodeTree(data)
+	var values []Value
+	effective.walk(nil, func(path []string, value interface{}) {
+		key := strings.Join(path, ".")
+		source, ok := r.Sources[key]
+		if !ok {
+			source = SourceDefault
+		}
+		data, _ := json.Marshal(redact(path[len(path)-1], value))
+		values = append(values, Value{key, string(data), source})
+	})
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Path < values[j].Path
+	})
+	return values
+}
+
+func readTree(path string) (tree, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := decodeTree(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return t, nil
+}
+
+func decodeTree(data []byte) (tree, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() //keep integers as they are
+	var t tree
+	if err := decoder.Decode(&t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// merge copies the values of src into t, objects being merged recursively,
+// and records the source of each value copied
+func (t tree) merge(src tree, prefix []string, source string, sources map[string]string) {
+	for key, value := range src {
+		path := append(append([]string(nil), prefix...), key)
+		if object, ok := value.(map[string]interface{}); ok {
+			existing, ok := t[key].(map[string]interface{})
+			if !ok {
+				existing = map[string]interface{}{}
+				t[key] = existing
+			}
+			tree(existing).merge(object, path, source, sources)
+			continue
+		}
+		t[key] = value
+		record(sources, path, source)
+	}
+}
+
+// set replaces the value at path, creating the objects on the way
+func (t tree) set(path []string, value interface{}, source string, sources map[string]string) {
+	object := t
+	for _, key := range path[:len(path)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			object[key] = child
+		}
+		object = child
+	}
+	object[path[len(path)-1]] = value
+	record(sources, path, source)
+}
+
+func record(sources map[string]string, path []string, source string) {
+	key := strings.Join(path, ".")
+	for existing := range sources {
+		if strings.HasPrefix(existing, key+".") {
+			delete(sources, existing) //replaced by a value that is not an object
+		}
+	}
+	sources[key] = source
+}
+
+// walk calls fn for each value that is not an object, arrays included
+func (t tree) walk(prefix []string, fn func(path []string, value interface{})) {
+	for key, value := range t {
+		path := append(append([]string(nil), prefix...), key)
+		if object, ok := value.(map[string]interface{}); ok && len(object) > 0 {
+			tree(object).walk(path, fn)
+			continue
+		}
+		fn(path, value)
+	}
+}
+
+// secrets are the fields never shown in explanations
+var secrets = map[string]bool{"password": true, "secret": true}
+
+func redact(key string, value interface{}) interface{} {
+	if secrets[key] && value != "" {
+		return "********"
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = redact(k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redact("", item)
+		}
+		return redacted
+	}
+	return value
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/mqttbridge"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func explained(r Resolved) map[string]Value {
+	values := make(map[string]Value)
+	for _, v := range r.Explain() {
+		values[v.Path] = v
+	}
+	return values
+}
+
+func TestLayersPrecedence(t *testing.T) {
+	policy := writePolicy(t, `{"version":1,"hookTimeout":20,"mqtt":{"broker":"tcp://corp:1883","password":"policy"},"profiles":{"default":{"interval":120}}}`)
+	user := Default()
+	require.NoError(t, user.CreateProfile("office", Profile{Icon: "man", Color: "red"}))
+	user.HookTimeout = 5
+
+	r := Layers{
+		PolicyPath: policy,
+		LookupEnv:  env(map[string]string{"AMM_PROFILE": "office", "AMM_ICON": "cloud", "AMM_COLOR": "white", "AMM_MQTT_USERNAME": "me"}),
+		Flags:      map[string]string{"color": ""},
+	}.Resolve(user, "settings")
+
+	assert.Empty(t, r.Problems)
+	s := r.Settings
+	assert.Equal(t, "office", s.ActiveProfile, "env wins over the user settings")
+	assert.Equal(t, Profile{Icon: "cloud", Color: ""}, s.Current(), "flags win over env, env wins over the user settings")
+	assert.Equal(t, 120, s.Profiles["default"].Interval, "policy values are used when the user did not set them")
+	assert.Equal(t, 5, s.HookTimeout, "user settings win over the policy")
+	assert.Equal(t, &mqttbridge.Config{Broker: "tcp://corp:1883", Username: "me", Password: "policy"}, s.MQTT)
+
+	values := explained(r)
+	assert.Equal(t, Value{"activeProfile", `"office"`, "env AMM_PROFILE"}, values["activeProfile"])
+	assert.Equal(t, Value{"profiles.office.color", `""`, "flag -color"}, values["profiles.office.color"])
+	assert.Equal(t, Value{"profiles.office.icon", `"cloud"`, "env AMM_ICON"}, values["profiles.office.icon"])
+	assert.Equal(t, Value{"profiles.default.interval", "120", "policy " + policy}, values["profiles.default.interval"])
+	assert.Equal(t, Value{"hookTimeout", "5", "settings"}, values["hookTimeout"])
+	assert.Equal(t, `"********"`, values["mqtt.password"].Value, "secrets should not be shown")
+
+	assert.Equal(t, user.ActiveProfile, DefaultProfileName, "the user settings should not change")
+}
+
+func TestLayersDefaultsAndProblems(t *testing.T) {
+	r := Layers{
+		PolicyPath: filepath.Join(t.TempDir(), "missing.json"),
+		LookupEnv:  env(map[string]string{"AMM_INTERVAL": "soon", "AMM_HOOK_TIMEOUT": "-3"}),
+	}.Resolve(Default(), "settings")
+	assert.Equal(t, []Problem{
+		{"env AMM_INTERVAL", `invalid value: "soon" is not a number`},
+		{"hookTimeout", "invalid value: must not be negative"},
+	}, r.Problems)
+	assert.Equal(t, Default().Profiles, r.Settings.Profiles)
+	assert.Equal(t, 0, r.Settings.HookTimeout, "invalid values keep their default")
+	assert.Equal(t, SourceDefault, r.Sources["hookTimeout"], "invalid values are not explained by their layer")
+
+	r = Layers{PolicyPath: writePolicy(t, `{"hookTimeout":`)}.Resolve(Default(), "settings")
+	require.Len(t, r.Problems, 1, "an unreadable policy is reported")
+	assert.Equal(t, Default().Profiles, r.Settings.Profiles)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentVersion is the version of the settings schema written by this app
+const CurrentVersion = 2
+
+// migrations[i] upgrades raw settings from version i to version i+1. Add a
+// step here, and bump CurrentVersion, whenever a field is renamed, moved or
+// changes meaning.
+var migrations = []func(raw map[string]json.RawMessage) error{
+	migrateV0,
+	migrateV1,
+}
+
+// Report describes what happened while loading the settings file
+type Report struct {
+	FromVersion int       //version found in the file
+	Backup      string    //copy of the original file, only set if migrated
+	Recovered   string    //backup loaded because the file was corrupt
+	Problems    []Problem //fields that were not used as is
+}
+
+// upgrade decodes the content of a settings file, migrating it in memory
+// if it was written by an older version of the app
+func upgrade(data []byte) (AppSettings, Report, error) {
+	var report Report
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Default(), report, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	if raw == nil { //the file contained null
+		raw = map[string]json.RawMessage{}
+	}
+
+	if versionJSON, ok := raw["version"]; ok {
+		if err := json.Unmarshal(versionJSON, &report.FromVersion); err != nil || report.FromVersion < 0 {
+			return Default(), report, fmt.Errorf("%w: invalid version %s", ErrCorrupt, versionJSON)
+		}
+	}
+	if report.FromVersion < CurrentVersion {
+		if err := migrate(raw, report.FromVersion); err != nil {
+			return Default(), report, err
+		}
+	}
+
+	s, problems, err := decodeFields(raw)
+	report.Problems = problems
+	return s, report, err
+}
+
+// migrate upgrades raw settings in place from the given version to the
+// current one
+func migrate(raw map[string]json.RawMessage, from int) error {
+	for version := from; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return fmt.Errorf("migrating settings from version %d: %w", version, err)
+		}
+		raw["version"] = json.RawMessage(fmt.Sprint(version + 1))
+	}
+	return nil
+}
+
+// migrateV0 upgrades the files written before versioning. The tray used to
+// show the mouse for any unknown icon and tint in blue for any unknown color,
+// store what was actually displayed.
+func migrateV0(raw map[string]json.RawMessage) error {
+	var icon, color string
+	if value, ok := raw["icon"]; ok && json.Unmarshal(value, &icon) == nil && oneOf(icon, Icons) != nil {
+		raw["icon"] = json.RawMessage(`"mouse"`)
+	}
+	if value, ok := raw["color"]; ok && json.Unmarshal(value, &color) == nil && oneOf(color, Colors) != nil {
+		raw["color"] = json.RawMessage(`"blue"`)
+	}
+	return nil
+}
+
+// migrateV1 moves the icon and color into the settings of a default profile
+func migrateV1(raw map[string]json.RawMessage) error {
+	profile := map[string]json.RawMessage{}
+	for _, key := range []string{"icon", "color"} {
+		if value, ok := raw[key]; ok {
+			profile[key] = value
+			delete(raw, key)
+		}
+	}
+	profiles, err := json.Marshal(map[string]interface{}{DefaultProfileName: profile})
+	if err != nil {
+		return err
+	}
+	raw["profiles"] = profiles
+	raw["activeProfile"] = json.RawMessage(`"` + DefaultProfileName + `"`)
+	return nil
+}
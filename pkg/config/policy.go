@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when a change touches a setting locked by the policy
+var ErrLocked = errors.New("locked by the system policy")
+
+// Policy is the read-only file administrators use to set defaults for every
+// user, and to enforce settings and limits:
+//
+//	{
+//	  "hookTimeout": 20,
+//	  "locked": {"activeProfile": "default", "profiles": {"*": {"interval": 300}}},
+//	  "businessHours": {"days": ["mon", "tue", "wed", "thu", "fri"], "from": "08:00", "to": "18:00"},
+//	  "maxKeepAwake": "4h"
+//	}
+//
+// The settings at the top level are defaults the user can change. Those of
+// locked win over every other source and cannot be changed; "*" stands for
+// any profile, and locking an object or array locks everything in it.
+type Policy struct {
+	Path string
+	// BusinessHours are when the mouse may be moved, always when nil
+	BusinessHours *BusinessHours
+	// MaxKeepAwake stops moving the mouse once nobody used the machine for
+	// that long, no limit when 0
+	MaxKeepAwake time.Duration
+
+	defaults tree
+	locked   tree
+}
+
+// policyFields are the keys of the policy that are not default settings
+var policyFields = map[string]bool{"locked": true, "businessHours": true, "maxKeepAwake": true, "version": true}
+
+// BusinessHours allow moving the mouse between two times of the day, in
+// local time, on some days of the week
+type BusinessHours struct {
+	Days []string `json:"days,omitempty"` //"mon" to "sun", every day when empty
+	From string   `json:"from"`           //"08:00"
+	To   string   `json:"to"`             //"18:00", before From to end the next day
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// LoadPolicy reads a policy file. A missing file is an empty policy.
+func LoadPolicy(path string) (Policy, error) {
+	policy := Policy{Path: path}
+	t, err := readTree(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+
+	var limits struct {
+		Locked        map[string]interface{} `json:"locked"`
+		BusinessHours *BusinessHours         `json:"businessHours"`
+		MaxKeepAwake  string                 `json:"maxKeepAwake"`
+	}
+	data, _ := json.Marshal(t)
+	if err := json.Unmarshal(data, &limits); err != nil {
+		return policy, fmt.Errorf("%v: %w", path, err)
+	}
+	if limits.BusinessHours != nil {
+		if err := limits.BusinessHours.validate(); err != nil {
+			return policy, fmt.Errorf("%v: businessHours: %w", path, err)
+		}
+	}
+	if limits.MaxKeepAwake != "" {
+		policy.MaxKeepAwake, err = time.ParseDuration(limits.MaxKeepAwake)
+		if err != nil || policy.MaxKeepAwake <= 0 {
+			return policy, fmt.Errorf("%v: maxKeepAwake: %q is not a positive duration such as 4h", path, limits.MaxKeepAwake)
+		}
+	}
+	policy.BusinessHours = limits.BusinessHours
+	policy.locked, _ = t["locked"].(map[string]interface{}) //numbers kept as in the file
+	delete(policy.locked, "version")
+	policy.defaults = tree{}
+	for key, value := range t {
+		if !policyFields[key] {
+			policy.defaults[key] = value
+		}
+	}
+	return policy, nil
+}
+
+func (h BusinessHours) validate() error {
+	for _, day := range h.Days {
+		if err := oneOf(day, weekdays); err != nil {
+			return err
+		}
+	}
+	for _, clock := range []string{h.From, h.To} {
+		if _, err := time.Parse("15:04", clock); err != nil {
+			return fmt.Errorf("%q is not a time such as 08:00", clock)
+		}
+	}
+	return nil
+}
+
+// ParseBusinessHours reads hours written as "08:00-18:00", optionally
+// preceded by days, e.g. "mon,tue,wed,thu,fri 08:00-18:00"
+func ParseBusinessHours(text string) (*BusinessHours, error) {
+	fields := strings.Fields(text)
+	var h BusinessHours
+	if len(fields) == 2 {
+		h.Days = strings.Split(fields[0], ",")
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("%q is not hours such as mon,fri 08:00-18:00", text)
+	}
+	var ok bool
+	if h.From, h.To, ok = strings.Cut(fields[0], "-"); !ok {
+		return nil, fmt.Errorf("%q is not hours such as mon,fri 08:00-18:00", text)
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// String writes the hours as read by ParseBusinessHours
+func (h BusinessHours) String() string {
+	if len(h.Days) == 0 {
+		return h.From + "-" + h.To
+	}
+	return strings.Join(h.Days, ",") + " " + h.From + "-" + h.To
+}
+
+// Allows tells if the mouse may be moved at the given time
+func (h BusinessHours) Allows(t time.Time) bool {
+	from, _ := time.Parse("15:04", h.From)
+	to, _ := time.Parse("15:04", h.To)
+	minutes := t.Hour()*60 + t.Minute()
+	start, end := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+	day := t
+	var within bool
+	if start <= end {
+		within = minutes >= start && minutes < end
+	} else { //ends the next day
+		within = minutes >= start || minutes < end
+		if minutes < end {
+			day = t.AddDate(0, 0, -1) //started the day before
+		}
+	}
+	if !within || len(h.Days) == 0 {
+		return within
+	}
+	return oneOf(weekdays[day.Weekday()], h.Days) == nil
+}
+
+// Locks tells if the setting at path, e.g. "profiles.office.icon", is
+// locked by the policy
+func (p Policy) Locks(path string) bool {
+	_, locked := p.lockedValue(strings.Split(path, "."))
+	return locked
+}
+
+// lockedValue returns the value enforced for the setting at path, nil if
+// the policy removes it
+func (p Policy) lockedValue(path []string) (interface{}, bool) {
+	var value interface{} = map[string]interface{}(p.locked)
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, true //a parent is locked as a whole
+		}
+		if value, ok = object[key]; !ok {
+			if value, ok = object["*"]; !ok {
+				return nil, false
+			}
+		}
+	}
+	return value, true
+}
+
+// CheckChange returns ErrLocked if going from before to after changes a
+// locked setting to a value other than the one enforced
+func (p Policy) CheckChange(before, after AppSettings) error {
+	paths, _, newValues := diff(before, after)
+	for _, path := range paths {
+		if locked, ok := p.lockedValue(strings.Split(path, ".")); ok && !reflect.DeepEqual(locked, newValues[path]) {
+			return fmt.Errorf("%v: %w", path, ErrLocked)
+		}
+	}
+	return nil
+}
+
+// enforce copies the locked values into t, "*" standing for every key of t
+func (t tree) enforce(locked tree, prefix []string, source string, sources map[string]string) {
+	for key, value := range locked {
+		keys := []string{key}
+		if key == "*" {
+			keys = keys[:0]
+			for existing := range t {
+				keys = append(keys, existing)
+			}
+		}
+		for _, key := range keys {
+			path := append(append([]string(nil), prefix...), key)
+			if object, ok := value.(map[string]interface{}); ok {
+				existing, ok := t[key].(map[string]interface{})
+				if !ok {
+					existing = map[string]interface{}{}
+					t[key] = existing
+				}
+				tree(existing).enforce(object, path, source, sources)
+				continue
+			}
+			t[key] = value
+			record(sources, path, source)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{
+	"hookTimeout": 20,
+	"locked": {"hookTimeout": 10, "mqtt": null, "profiles": {"*": {"interval": 300}}},
+	"businessHours": {"days": ["mon", "tue", "wed", "thu", "fri"], "from": "08:00", "to": "18:00"},
+	"maxKeepAwake": "4h"
+}`
+
+func TestLockedSettingsWinOverEverySource(t *testing.T) {
+	path := writePolicy(t, testPolicy)
+	user := Default()
+	require.NoError(t, user.CreateProfile("office", Profile{Icon: "man", Color: "red", Interval: 60}))
+	user.HookTimeout = 5
+
+	r := Layers{
+		PolicyPath: path,
+		LookupEnv:  env(map[string]string{"AMM_HOOK_TIMEOUT": "30", "AMM_MQTT_BROKER": "tcp://home:1883"}),
+		Flags:      map[string]string{"interval": "60"},
+	}.Resolve(user, "settings")
+
+	assert.Empty(t, r.Problems)
+	assert.Equal(t, 10, r.Settings.HookTimeout)
+	assert.Nil(t, r.Settings.MQTT)
+	assert.Equal(t, 300, r.Settings.Profiles["default"].Interval)
+	assert.Equal(t, Profile{Icon: "man", Color: "red", Interval: 300}, r.Settings.Profiles["office"])
+	assert.Equal(t, "policy "+path+" (locked)", explained(r)["profiles.office.interval"].Source)
+	assert.Equal(t, 4*time.Hour, r.Policy.MaxKeepAwake)
+
+	assert.True(t, r.Policy.Locks("hookTimeout"))
+	assert.True(t, r.Policy.Locks("mqtt.broker"), "locking an object locks its fields")
+	assert.True(t, r.Policy.Locks("profiles.office.interval"))
+	assert.False(t, r.Policy.Locks("profiles.office.icon"))
+	assert.False(t, r.Policy.Locks("activeProfile"))
+}
+
+func TestCheckChange(t *testing.T) {
+	policy, err := LoadPolicy(writePolicy(t, testPolicy))
+	require.NoError(t, err)
+	before := Default()
+
+	after := before
+	require.NoError(t, after.CreateProfile("office", Profile{Icon: "man", Color: "red", Interval: 300}))
+	assert.NoError(t, policy.CheckChange(before, after), "the enforced value can be used")
+
+	after = before
+	after.SetCurrent(Profile{Icon: "cloud", Interval: 120})
+	assert.True(t, errors.Is(policy.CheckChange(before, after), ErrLocked))
+
+	after = before
+	after.HookTimeout = 3
+	assert.EqualError(t, policy.CheckChange(before, after), "hookTimeout: locked by the system policy")
+
+	after = before
+	after.SetCurrent(Profile{Icon: "cloud"})
+	assert.NoError(t, policy.CheckChange(before, after), "icons are not locked")
+}
+
+func TestInvalidPolicy(t *testing.T) {
+	for _, content := range []string{
+		`{"locked":`,
+		`{"locked": []}`,
+		`{"businessHours": {"from": "8am", "to": "18:00"}}`,
+		`{"businessHours": {"days": ["monday"], "from": "08:00", "to": "18:00"}}`,
+		`{"maxKeepAwake": "forever"}`,
+		`{"maxKeepAwake": "-1h"}`,
+	} {
+		_, err := LoadPolicy(writePolicy(t, content))
+		assert.Error(t, err, content)
+	}
+
+	policy, err := LoadPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	require.NoError(t, err, "the policy is optional")
+	assert.False(t, policy.Locks("hookTimeout"))
+}
+
+func TestBusinessHours(t *testing.T) {
+	day := BusinessHours{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "08:00", To: "18:00"}
+	night := BusinessHours{Days: []string{"fri"}, From: "22:00", To: "06:00"}
+	monday := time.Date(2024, 5, 6, 0, 0, 0, 0, time.Local)
+	at := func(days int, clock string) time.Time {
+		d, _ := time.ParseDuration(clock)
+		return monday.AddDate(0, 0, days).Add(d)
+	}
+
+	assert.True(t, day.Allows(at(0, "8h")))
+	assert.True(t, day.Allows(at(4, "17h59m")))
+	assert.False(t, day.Allows(at(0, "18h")))
+	assert.False(t, day.Allows(at(0, "7h59m")))
+	assert.False(t, day.Allows(at(5, "12h")), "saturday")
+
+	assert.True(t, night.Allows(at(4, "23h")))
+	assert.True(t, night.Allows(at(5, "5h")), "friday night ends on saturday")
+	assert.False(t, night.Allows(at(4, "5h")), "thursday night")
+	assert.True(t, BusinessHours{From: "00:00", To: "23:59"}.Allows(at(6, "12h")), "every day when no days are given")
+}
+
+func TestParseBusinessHours(t *testing.T) {
+	h, err := ParseBusinessHours("mon,fri 08:00-18:00")
+	require.NoError(t, err)
+	assert.Equal(t, &BusinessHours{Days: []string{"mon", "fri"}, From: "08:00", To: "18:00"}, h)
+	assert.Equal(t, "mon,fri 08:00-18:00", h.String())
+
+	h, err = ParseBusinessHours("22:00-06:00")
+	require.NoError(t, err)
+	assert.Equal(t, &BusinessHours{From: "22:00", To: "06:00"}, h, "every day when no days are given")
+
+	for _, text := range []string{"", "08:00", "monday 08:00-18:00", "08:00-25:00", "mon 08:00-18:00 extra"} {
+		_, err := ParseBusinessHours(text)
+		assert.Error(t, err, text)
+	}
+}
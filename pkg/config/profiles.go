@@ -0,0 +1,237 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+)
+
+// DefaultProfileName is the profile created on first launch
+const DefaultProfileName = "default"
+
+// Limits of the interval between two activity checks, in seconds, as
+// accepted by the activity tracker
+const (
+	DefaultInterval = 60
+	MinInterval     = 60
+	MaxInterval     = 300
+)
+
+const maxProfileNameLength = 40
+
+// Icons are the names of the bundled icons. Custom icons are named after
+// their file in the icons folder, e.g. rocket.png, and themes after their
+// directory, e.g. theme:neon.
+var Icons = []string{"mouse", "cloud", "geometric", "man"}
+
+// Colors are the named tints of the menu, "" meaning the system template
+// icon. Any #RRGGBB or #RRGGBBAA value is accepted as well.
+var Colors = []string{"", "blue", "white", "red"}
+
+// Strategies are the ways of moving the mouse, "" meaning jiggle
+var Strategies = []string{"", "jiggle", "nudge"}
+
+// Profile groups the settings that change together, such as "office" or
+// "presentation"
+type Profile struct {
+	Icon  string `json:"icon"`
+	Color string `json:"color"`
+	// PausedColor and ErrorColor replace Color while paused and after a
+	// failed move, Color being used when they are empty
+	PausedColor string `json:"pausedColor,omitempty"`
+	ErrorColor  string `json:"errorColor,omitempty"`
+	// Blend is how the color is applied to the icon: tint, the default,
+	// multiply or overlay
+	Blend string `json:"blend,omitempty"`
+	// Interval is the number of seconds without activity before the mouse
+	// moves, 0 meaning DefaultInterval
+	Interval int `json:"interval,omitempty"`
+	// Strategy is how the mouse moves: jiggle, the default, or nudge
+	Strategy string `json:"strategy,omitempty"`
+	// Schedule restricts the moves to some hours, always allowed when nil
+	Schedule *BusinessHours `json:"schedule,omitempty"`
+}
+
+// DefaultProfile returns the profile used when there is no settings file
+func DefaultProfile() Profile {
+	return Profile{
+		Icon:  "mouse",
+		Color: "blue",
+	}
+}
+
+// IntervalSeconds returns the interval, with the default applied
+func (p Profile) IntervalSeconds() int {
+	if p.Interval == 0 {
+		return DefaultInterval
+	}
+	return p.Interval
+}
+
+// Validate checks the values of the profile
+func (p Profile) Validate() error {
+	if icons.IsCustom(p.Icon) {
+		if err := icons.ValidateName(p.Icon); err != nil {
+			return fmt.Errorf("icon: %w", err)
+		}
+	} else if icons.IsTheme(p.Icon) {
+		if err := icons.ValidateThemeName(p.Icon); err != nil {
+			return fmt.Errorf("icon: %w", err)
+		}
+	} else if err := oneOf(p.Icon, Icons); err != nil {
+		return fmt.Errorf("icon: %w", err)
+	}
+	for _, c := range []struct{ field, value string }{{"color", p.Color}, {"pausedColor", p.PausedColor}, {"errorColor", p.ErrorColor}} {
+		if err := validateColor(c.value); err != nil {
+			return fmt.Errorf("%v: %w", c.field, err)
+		}
+	}
+	if _, err := icons.ParseBlendMode(p.Blend); err != nil {
+		return fmt.Errorf("blend: %w", err)
+	}
+	if p.Interval != 0 && (p.Interval < MinInterval || p.Interval > MaxInterval) {
+		return fmt.Errorf("interval: %d is not between %d and %d seconds", p.Interval, MinInterval, MaxInterval)
+	}
+	if err := oneOf(p.Strategy, Strategies); err != nil {
+		return fmt.Errorf("strategy: %w", err)
+	}
+	if p.Schedule != nil {
+		if err := p.Schedule.validate(); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+	}
+	return nil
+}
+
+// StateColor returns the color of the icon for the state of the mouse mover
+func (p Profile) StateColor(paused, failing bool) string {
+	switch {
+	case failing && p.ErrorColor != "":
+		return p.ErrorColor
+	case paused && p.PausedColor != "":
+		return p.PausedColor
+	}
+	return p.Color
+}
+
+// validateColor checks a color name or hex value, "" being the system color
+func validateColor(color string) error {
+	if color == "" {
+		return nil
+	}
+	_, err := icons.ParseColor(color)
+	return err
+}
+
+func validateProfiles(profiles map[string]Profile) error {
+	if len(profiles) == 0 {
+		return errors.New("at least one profile is required")
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := ValidateProfileName(name); err != nil {
+			return err
+		}
+		if err := profiles[name].Validate(); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateProfileName checks that a name can be used for a profile
+func ValidateProfileName(name string) error {
+	if strings.TrimSpace(name) != name || name == "" {
+		return fmt.Errorf("profile name %q must not be empty or start or end with spaces", name)
+	}
+	if len(name) > maxProfileNameLength {
+		return fmt.Errorf("profile name %q is longer than %d characters", name, maxProfileNameLength)
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the profiles in alphabetical order
+func (s AppSettings) ProfileNames() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Current returns the active profile
+func (s AppSettings) Current() Profile {
+	if p, ok := s.Profiles[s.ActiveProfile]; ok {
+		return p
+	}
+	return DefaultProfile()
+}
+
+// SetCurrent replaces the active profile
+func (s *AppSettings) SetCurrent(p Profile) {
+	s.SetProfile(s.ActiveProfile, p)
+}
+
+// CreateProfile adds a new profile
+func (s *AppSettings) CreateProfile(name string, p Profile) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	if _, exists := s.Profiles[name]; exists {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.SetProfile(name, p)
+	return nil
+}
+
+// CopyProfile creates a new profile with the values of an existing one
+func (s *AppSettings) CopyProfile(from, to string) error {
+	p, ok := s.Profiles[from]
+	if !ok {
+		return fmt.Errorf("no profile named %q", from)
+	}
+	return s.CreateProfile(to, p)
+}
+
+// SwitchProfile makes another profile the active one
+func (s *AppSettings) SwitchProfile(name string) error {
+	if _, ok := s.Profiles[name]; !ok {
+		return fmt.Errorf("no profile named %q", name)
+	}
+	s.ActiveProfile = name
+	return nil
+}
+
+// SetProfile adds or replaces a profile without validating it. The profiles
+// are copied first, settings values share their map otherwise.
+func (s *AppSettings) SetProfile(name string, p Profile) {
+	profiles := make(map[string]Profile, len(s.Profiles)+1)
+	for n, existing := range s.Profiles {
+		profiles[n] = existing
+	}
+	profiles[name] = p
+	s.Profiles = profiles
+}
+
+// UnmarshalJSON gives the fields missing from the file their default value
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile //without the UnmarshalJSON method
+	v := plain(DefaultProfile())
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = Profile(v)
+	return nil
+}
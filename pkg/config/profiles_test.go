@@ -0,0 +1,96 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCopyAndSwitchProfiles(t *testing.T) {
+	s := Default()
+	require.NoError(t, s.CreateProfile("presentation", Profile{Icon: "man", Color: "red", Interval: 300}))
+	require.NoError(t, s.CopyProfile("presentation", "office"))
+	assert.Equal(t, []string{"default", "office", "presentation"}, s.ProfileNames())
+
+	require.NoError(t, s.SwitchProfile("office"))
+	assert.Equal(t, "office", s.ActiveProfile)
+	assert.Equal(t, 300, s.Current().IntervalSeconds())
+
+	s.SetCurrent(Profile{Icon: "cloud", Color: ""})
+	assert.Equal(t, "man", s.Profiles["presentation"].Icon, "copies should not share changes")
+	assert.Equal(t, DefaultInterval, s.Current().IntervalSeconds())
+	assert.Empty(t, Validate(s))
+}
+
+func TestProfileErrors(t *testing.T) {
+	s := Default()
+	assert.Error(t, s.CreateProfile("default", DefaultProfile()), "duplicate name")
+	assert.Error(t, s.CreateProfile(" office", DefaultProfile()), "surrounding spaces")
+	assert.Error(t, s.CreateProfile(strings.Repeat("a", 41), DefaultProfile()), "name too long")
+	assert.Error(t, s.CreateProfile("office", Profile{Icon: "rocket"}), "unknown icon")
+	assert.Error(t, s.CreateProfile("office", Profile{Icon: "../rocket.png"}), "custom icon outside of the icons folder")
+	assert.Error(t, s.CreateProfile("office", Profile{Icon: "mouse", Interval: 30}), "interval too short")
+	assert.Error(t, s.CreateProfile("office", Profile{Icon: "mouse", Blend: "screen"}), "unknown blend mode")
+	assert.Error(t, s.CopyProfile("missing", "office"))
+	assert.Error(t, s.SwitchProfile("missing"))
+	assert.Equal(t, []string{"default"}, s.ProfileNames(), "failed calls should not change the settings")
+}
+
+func TestCustomIcons(t *testing.T) {
+	s := Default()
+	require.NoError(t, s.CreateProfile("office", Profile{Icon: "rocket.png", Color: ""}))
+	assert.Equal(t, "rocket.png", s.Profiles["office"].Icon)
+	require.NoError(t, s.CreateProfile("night", Profile{Icon: "theme:neon"}))
+	assert.ErrorContains(t, s.CreateProfile("other", Profile{Icon: "theme:../neon"}), "theme directory")
+}
+
+func TestStateColors(t *testing.T) {
+	p := Profile{Icon: "mouse", Color: "#1e90ff", PausedColor: "#80808080", ErrorColor: "red"}
+	require.NoError(t, p.Validate())
+	assert.Equal(t, "#1e90ff", p.StateColor(false, false))
+	assert.Equal(t, "#80808080", p.StateColor(true, false))
+	assert.Equal(t, "red", p.StateColor(true, true), "errors win over pauses")
+
+	p.ErrorColor = ""
+	assert.Equal(t, "#1e90ff", p.StateColor(false, true), "the active color is used when not set")
+	p.PausedColor = "#12345"
+	assert.ErrorContains(t, p.Validate(), "pausedColor")
+}
+
+func TestStrategyAndSchedule(t *testing.T) {
+	s, problems, err := Decode([]byte(`{"version":2,"profiles":{"office":{"strategy":"nudge","schedule":{"days":["mon"],"from":"08:00","to":"18:00"}}}}`))
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, "nudge", s.Current().Strategy)
+	assert.Equal(t, &BusinessHours{Days: []string{"mon"}, From: "08:00", To: "18:00"}, s.Current().Schedule)
+
+	assert.ErrorContains(t, Profile{Icon: "mouse", Strategy: "teleport"}.Validate(), "strategy")
+	assert.ErrorContains(t, Profile{Icon: "mouse", Schedule: &BusinessHours{From: "8h", To: "18:00"}}.Validate(), "schedule")
+}
+
+func TestColorPresets(t *testing.T) {
+	s := Default()
+	s.ColorPresets = []ColorPreset{{"Brand", "#ff6600"}, {"Night", "#22223380"}}
+	assert.Empty(t, Validate(s))
+	s.ColorPresets = append(s.ColorPresets, ColorPreset{"Brand", "#000000"})
+	assert.Len(t, Validate(s), 1, "names must be unique")
+	s.ColorPresets = []ColorPreset{{"Empty", ""}}
+	assert.Len(t, Validate(s), 1, "presets need a color")
+}
+
+func TestLanguage(t *testing.T) {
+	s := Default()
+	s.Language = "fr"
+	assert.Empty(t, Validate(s))
+	s.Language = "fr_FR"
+	assert.Len(t, Validate(s), 1, "languages are codes of the catalogs")
+}
+
+func TestChangingACopyDoesNotChangeTheOriginal(t *testing.T) {
+	original := Default()
+	changed := original
+	changed.SetCurrent(Profile{Icon: "man"})
+	assert.Equal(t, "mouse", original.Current().Icon)
+}
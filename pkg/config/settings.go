@@ -0,0 +1,338 @@
+/*
+Package config reads and writes the settings of the app.
+
+The settings file can be written in JSON, YAML or TOML, the format being
+given by its extension. All of them share the schema of settings.json.
+
+The settings file carries a schema version. Files written by older versions
+of the app are upgraded step by step when loaded (see migrations), after the
+original file has been backed up next to it.
+
+Unknown fields, and fields whose value cannot be used, are reported as
+Problems instead of being silently dropped: their original value is kept and
+written back when the settings are saved, unless the app changed that field
+in the meantime.
+*/
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
+	"github.com/Resousse/automatic-mouse-mover/pkg/icons"
+	"github.com/Resousse/automatic-mouse-mover/pkg/mqttbridge"
+	"github.com/Resousse/automatic-mouse-mover/pkg/webhook"
+)
+
+// AppSettings are the user preferences stored in settings.json
+type AppSettings struct {
+	Version       int                `json:"version"`
+	ActiveProfile string             `json:"activeProfile"`
+	Profiles      map[string]Profile `json:"profiles"`
+	MQTT          *mqttbridge.Config `json:"mqtt,omitempty"`
+	Webhooks      []webhook.Target   `json:"webhooks,omitempty"`
+	// HookTimeout is the number of seconds a hook script may run
+	HookTimeout int `json:"hookTimeout,omitempty"`
+	// ColorPresets are added to the colors of the menu
+	ColorPresets []ColorPreset `json:"colorPresets,omitempty"`
+	// HideTitle leaves out the countdown shown next to the tray icon
+	HideTitle bool `json:"hideTitle,omitempty"`
+	// DisableAnimation keeps the tray icon still when the mouse moves
+	DisableAnimation bool `json:"disableAnimation,omitempty"`
+	// Language of the menu, e.g. fr, "" for the one of the system
+	Language string `json:"language,omitempty"`
+
+	// kept so that saving does not lose what could not be loaded
+	preserved map[string]json.RawMessage
+	// JSON of the default value used in place of each invalid field
+	replaced map[string]string
+	// invalid profiles, left out of Profiles but written back by name
+	preservedProfiles map[string]json.RawMessage
+}
+
+// ColorPreset is a color of the menu defined by the user
+type ColorPreset struct {
+	Name  string `json:"name"`
+	Color string `json:"color"` //#RRGGBB or #RRGGBBAA
+}
+
+// Problem is a field of the settings file that could not be used as is
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// Default returns the settings used when there is no settings file
+func Default() AppSettings {
+	return AppSettings{
+		Version:       CurrentVersion,
+		ActiveProfile: DefaultProfileName,
+		Profiles: map[string]Profile{
+			DefaultProfileName: DefaultProfile(),
+		},
+	}
+}
+
+type validator struct {
+	field    string
+	validate func(s *AppSettings) error
+}
+
+// validators check the values that decode fine but cannot be used, in an
+// order where the fields a validator depends on are checked before it
+var validators = []validator{
+	{"profiles", func(s *AppSettings) error {
+		return validateProfiles(s.Profiles)
+	}},
+	{"activeProfile", func(s *AppSettings) error {
+		if _, ok := s.Profiles[s.ActiveProfile]; !ok {
+			return fmt.Errorf("no profile named %q", s.ActiveProfile)
+		}
+		return nil
+	}},
+	{"hookTimeout", func(s *AppSettings) error {
+		if s.HookTimeout < 0 {
+			return errors.New("must not be negative")
+		}
+		return nil
+	}},
+	{"colorPresets", func(s *AppSettings) error {
+		names := make(map[string]bool)
+		for _, preset := range s.ColorPresets {
+			if strings.TrimSpace(preset.Name) == "" || names[preset.Name] {
+				return fmt.Errorf("preset name %q is empty or used twice", preset.Name)
+			}
+			names[preset.Name] = true
+			if _, err := icons.ParseColor(preset.Color); err != nil {
+				return fmt.Errorf("preset %q: %w", preset.Name, err)
+			}
+		}
+		return nil
+	}},
+	{"language", func(s *AppSettings) error {
+		if s.Language != "" {
+			return oneOf(s.Language, i18n.Languages)
+		}
+		return nil
+	}},
+	{"mqtt", func(s *AppSettings) error {
+		if s.MQTT != nil {
+			_, err := mqttbridge.New(*s.MQTT)
+			return err
+		}
+		return nil
+	}},
+	{"webhooks", func(s *AppSettings) error {
+		if len(s.Webhooks) > 0 {
+			return webhook.Validate(s.Webhooks)
+		}
+		return nil
+	}},
+}
+
+// Validate lists the fields of the settings that cannot be used
+func Validate(s AppSettings) []Problem {
+	var problems []Problem
+	for _, v := range validators {
+		if err := v.validate(&s); err != nil {
+			problems = append(problems, Problem{v.field, "invalid value: " + err.Error()})
+		}
+	}
+	return problems
+}
+
+func oneOf(value string, allowed []string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %q", value, allowed)
+}
+
+// Decode reads settings at the current version. Fields that are unknown or
+// invalid are reported and keep their default value.
+func Decode(data []byte) (AppSettings, []Problem, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Default(), nil, err
+	}
+	return decodeFields(raw)
+}
+
+func decodeFields(raw map[string]json.RawMessage) (AppSettings, []Problem, error) {
+	s := Default()
+	defaults := Default()
+	var problems []Problem
+	fields := jsonFields(&s)
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field, known := fields[key]
+		if !known {
+			problems = append(problems, Problem{key, "unknown field"})
+			s.preserve(key, raw[key], "")
+			continue
+		}
+		field.Set(reflect.Zero(field.Type())) //maps would be merged otherwise
+		if key == "profiles" {
+			if profileProblems, ok := s.decodeProfiles(raw[key]); ok {
+				problems = append(problems, profileProblems...)
+				continue
+			}
+		}
+		if err := json.Unmarshal(raw[key], field.Addr().Interface()); err != nil {
+			problems = append(problems, Problem{key, "invalid value: " + err.Error()})
+			field.Set(jsonFields(&defaults)[key])
+			s.preserve(key, raw[key], s.fieldJSON(key))
+		}
+	}
+	for _, v := range validators {
+		if _, present := raw[v.field]; !present {
+			continue
+		}
+		if err := v.validate(&s); err != nil {
+			problems = append(problems, Problem{v.field, "invalid value: " + err.Error()})
+			fields[v.field].Set(jsonFields(&defaults)[v.field])
+			s.preserve(v.field, raw[v.field], s.fieldJSON(v.field))
+		}
+	}
+	if _, ok := s.Profiles[s.ActiveProfile]; !ok {
+		s.ActiveProfile = s.ProfileNames()[0]
+		if _, invalid := s.replaced["activeProfile"]; invalid {
+			//written back as long as the app does not switch profiles
+			s.replaced["activeProfile"] = s.fieldJSON("activeProfile")
+		}
+	}
+	if s.Version > CurrentVersion {
+		problems = append(problems, Problem{"version", fmt.Sprintf("written by a newer version of the app (%d)", s.Version)})
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Field < problems[j].Field
+	})
+	return s, problems, nil
+}
+
+// decodeProfiles reads the profiles one by one, so that an invalid profile
+// only drops itself. It returns false if profiles is not an object.
+func (s *AppSettings) decodeProfiles(data json.RawMessage) ([]Problem, bool) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return nil, false
+	}
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []Problem
+	s.Profiles = make(map[string]Profile, len(raw))
+	for _, name := range names {
+		var p Profile
+		err := ValidateProfileName(name)
+		if err == nil {
+			err = json.Unmarshal(raw[name], &p)
+		}
+		if err == nil {
+			err = p.Validate()
+		}
+		if err != nil {
+			problems = append(problems, Problem{"profiles." + name, "invalid value: " + err.Error()})
+			if s.preservedProfiles == nil {
+				s.preservedProfiles = make(map[string]json.RawMessage)
+			}
+			s.preservedProfiles[name] = raw[name]
+			continue
+		}
+		s.Profiles[name] = p
+	}
+	if len(s.Profiles) == 0 && len(s.preservedProfiles) > 0 {
+		s.Profiles = Default().Profiles //the invalid ones are still written back
+	}
+	return problems, true
+}
+
+// MarshalJSON writes the settings, along with the preserved fields
+func (s AppSettings) MarshalJSON() ([]byte, error) {
+	type plain AppSettings //without the MarshalJSON method
+	data, err := json.Marshal(plain(s))
+	if err != nil || len(s.preserved) == 0 && len(s.preservedProfiles) == 0 {
+		return data, err
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for key, value := range s.preserved {
+		defaultJSON, wasInvalid := s.replaced[key]
+		if wasInvalid && s.fieldJSON(key) != defaultJSON {
+			continue //changed by the app since, the new value wins
+		}
+		out[key] = value
+	}
+	if len(s.preservedProfiles) > 0 {
+		var profiles map[string]json.RawMessage
+		if err := json.Unmarshal(out["profiles"], &profiles); err != nil {
+			return nil, err
+		}
+		for name, value := range s.preservedProfiles {
+			if _, ok := profiles[name]; !ok { //a valid one made since wins
+				profiles[name] = value
+			}
+		}
+		if out["profiles"], err = json.Marshal(profiles); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(out)
+}
+
+// preserve keeps the raw value of a field that could not be loaded, along
+// with the JSON of the default value that replaced it, if any
+func (s *AppSettings) preserve(key string, value json.RawMessage, defaultJSON string) {
+	if s.preserved == nil {
+		s.preserved = make(map[string]json.RawMessage)
+		s.replaced = make(map[string]string)
+	}
+	s.preserved[key] = value
+	if defaultJSON != "" {
+		s.replaced[key] = defaultJSON
+	}
+}
+
+func (s *AppSettings) fieldJSON(key string) string {
+	field, ok := jsonFields(s)[key]
+	if !ok {
+		return ""
+	}
+	data, _ := json.Marshal(field.Interface())
+	return string(data)
+}
+
+// jsonFields maps the JSON names of the settings to their fields
+func jsonFields(s *AppSettings) map[string]reflect.Value {
+	v := reflect.ValueOf(s).Elem()
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrCorrupt is returned when the settings file cannot be parsed at all
+var ErrCorrupt = errors.New("settings file is corrupt")
+
+// Store reads and writes a settings file without ever leaving it half
+// written: the new content goes to a temporary file which is flushed to disk
+// and then renamed over the old one. A copy of the last saved settings is
+// kept next to the file and used if the file turns out to be corrupt anyway.
+type Store struct {
+	path string
+}
+
+// NewStore returns a store for the settings file at path
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Path of the settings file
+func (st *Store) Path() string {
+	return st.path
+}
+
+// BackupPath is the copy of the last successfully saved settings
+func (st *Store) BackupPath() string {
+	return st.path + ".bak"
+}
+
+// Load reads the settings file, upgrading and rewriting it first if it was
+// written by an older version of the app, or restoring it from the backup
+// if it is corrupt. Missing or invalid fields keep their default value and
+// are listed in the report.
+func (st *Store) Load() (AppSettings, Report, error) {
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		return Default(), Report{}, err
+	}
+	s, report, err := st.upgrade(data)
+	if errors.Is(err, ErrCorrupt) {
+		return st.recover(data, err)
+	}
+	if err != nil {
+		return s, report, fmt.Errorf("%v: %w", st.path, err)
+	}
+	if report.FromVersion >= CurrentVersion {
+		return s, report, nil
+	}
+
+	report.Backup = fmt.Sprintf("%v.v%d.bak", st.path, report.FromVersion)
+	if err := writeFileAtomic(report.Backup, data); err != nil {
+		return s, report, fmt.Errorf("cannot back up settings before migrating them: %w", err)
+	}
+	return s, report, st.Save(s)
+}
+
+// Read decodes the settings file without writing anything, unlike Load
+func (st *Store) Read() (AppSettings, Report, error) {
+	data, err := os.ReadFile(st.path)
+	if err != nil {
+		return Default(), Report{}, err
+	}
+	return st.upgrade(data)
+}
+
+// Parse decodes the content of a settings file named name, e.g.
+// settings.yaml, migrating it in memory if needed
+func Parse(name string, data []byte) (AppSettings, Report, error) {
+	return NewStore(name).upgrade(data)
+}
+
+// format of the file, other extensions being read as JSON
+func (st *Store) format() Format {
+	format, err := FormatOf(st.path)
+	if err != nil {
+		return JSON
+	}
+	return format
+}
+
+// upgrade decodes the content of the file, whatever its format
+func (st *Store) upgrade(data []byte) (AppSettings, Report, error) {
+	converted, err := toJSON(st.format(), data)
+	if err != nil {
+		return Default(), Report{}, fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return upgrade(converted)
+}
+
+// Convert writes the settings of a file to another one, in the format given
+// by its extension
+func Convert(from, to string) (Report, error) {
+	for _, path := range []string{from, to} {
+		if _, err := FormatOf(path); err != nil {
+			return Report{}, err
+		}
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return Report{}, err
+	}
+	s, report, err := NewStore(from).upgrade(data)
+	if err != nil {
+		return report, fmt.Errorf("%v: %w", from, err)
+	}
+	return report, NewStore(to).Save(s)
+}
+
+// recover loads the backup in place of the corrupt file, which is kept
+// aside for inspection
+func (st *Store) recover(corrupt []byte, loadErr error) (AppSettings, Report, error) {
+	loadErr = fmt.Errorf("%v: %w", st.path, loadErr)
+	data, err := os.ReadFile(st.BackupPath())
+	if err != nil {
+		return Default(), Report{}, loadErr
+	}
+	s, report, err := st.upgrade(data)
+	if err != nil {
+		return Default(), Report{}, loadErr
+	}
+	report.Recovered = st.BackupPath()
+	if err := writeFileAtomic(st.path+".corrupt", corrupt); err != nil {
+		return s, report, err
+	}
+	return s, report, st.Save(s)
+}
+
+// Save validates the settings and replaces the file with them. Files written
+// by a newer version of the app keep their version.
+func (st *Store) Save(s AppSettings) error {
+	if problems := Validate(s); len(problems) > 0 {
+		messages := make([]string, len(problems))
+		for i, problem := range problems {
+			messages[i] = problem.String()
+		}
+		return fmt.Errorf("refusing to save invalid settings: %v", strings.Join(messages, ", "))
+	}
+	if s.Version < CurrentVersion {
+		s.Version = CurrentVersion
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(s); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if format := st.format(); format != JSON {
+		previous, _ := os.ReadFile(st.path)
+		var err error
+		if data, err = fromJSON(format, data, previous); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(st.path, data); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(st.BackupPath(), data); err != nil {
+		return fmt.Errorf("settings saved but not backed up: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data so that readers, and the file after
+// a crash, see either the old content or the new one
+func writeFileAtomic(path string, data []byte) (err error) {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir makes the rename durable. Not every platform can open a directory
+// for that, so this is best effort.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	d.Sync()
+}
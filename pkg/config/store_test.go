@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveWritesFileAndBackup(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(filepath.Join(dir, "settings.json"))
+	s := Default()
+	s.SetCurrent(Profile{Icon: "cloud"})
+	require.NoError(t, store.Save(s))
+
+	data, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+	backup, err := os.ReadFile(store.BackupPath())
+	require.NoError(t, err)
+	assert.Equal(t, data, backup, "backup should hold the last saved settings")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "no temporary file should be left behind")
+}
+
+func TestSaveRejectsInvalidSettings(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	require.NoError(t, store.Save(Default()))
+	before, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+
+	s := Default()
+	s.SetCurrent(Profile{Icon: "mouse", Color: "chartreuse"})
+	assert.Error(t, store.Save(s))
+
+	after, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "invalid settings must not be written")
+}
+
+func TestLoadRecoversCorruptFileFromBackup(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	s := Default()
+	s.SetCurrent(Profile{Icon: "man"})
+	require.NoError(t, store.Save(s))
+
+	for _, corrupt := range []string{"", `{"profiles":{"ma`} {
+		require.NoError(t, os.WriteFile(store.Path(), []byte(corrupt), 0o644))
+
+		loaded, report, err := store.Load()
+		require.NoError(t, err)
+		assert.Equal(t, store.BackupPath(), report.Recovered)
+		assert.Equal(t, "man", loaded.Current().Icon)
+
+		kept, err := os.ReadFile(store.Path() + ".corrupt")
+		require.NoError(t, err)
+		assert.Equal(t, corrupt, string(kept), "corrupt file should be kept aside")
+
+		_, report, err = store.Load()
+		require.NoError(t, err)
+		assert.Empty(t, report.Recovered, "restored file should load directly")
+	}
+}
+
+func TestLoadCorruptWithoutBackup(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	require.NoError(t, os.WriteFile(store.Path(), []byte("{"), 0o644))
+
+	s, _, err := store.Load()
+	assert.ErrorIs(t, err, ErrCorrupt)
+	assert.Equal(t, Default(), s)
+
+	require.NoError(t, os.WriteFile(store.BackupPath(), []byte("garbage"), 0o644))
+	_, _, err = store.Load()
+	assert.ErrorIs(t, err, ErrCorrupt, "a corrupt backup cannot be used either")
+}
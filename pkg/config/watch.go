@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Change is sent by a Watcher once the settings file was modified
+type Change struct {
+	Settings AppSettings
+	Report   Report
+	Err      error //the file could not be read, Settings are not usable
+}
+
+// Watcher follows the changes made to the settings file by other programs
+type Watcher struct {
+	// Changes receives the new content of the file
+	Changes <-chan Change
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// Watch starts following the settings file. Editors and sync tools often
+// write a file in several steps, so the file is only read once it has not
+// changed for the debounce duration. Nothing is ever written back: a file
+// that is still invalid after that is reported, not restored.
+func (st *Store) Watch(debounce time.Duration) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	//watch the directory, the file itself is replaced on atomic writes
+	if err := watcher.Add(filepath.Dir(st.path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	changes := make(chan Change)
+	w := &Watcher{
+		Changes: changes,
+		watcher: watcher,
+		done:    make(chan struct{}),
+	}
+	go w.run(st, debounce, changes)
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.watcher.Close()
+}
+
+func (w *Watcher) run(st *Store, debounce time.Duration, changes chan<- Change) {
+	path := filepath.Clean(st.path)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(e.Name) == path && e.Has(fsnotify.Write|fsnotify.Create) {
+				timer.Reset(debounce)
+			}
+		case <-w.watcher.Errors:
+			//the watcher keeps running, a later change will resync us
+		case <-timer.C:
+			change := Change{}
+			change.Settings, change.Report, change.Err = st.Read()
+			select {
+			case changes <- change:
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDebounce = 100 * time.Millisecond
+
+func nextChange(t *testing.T, w *Watcher) Change {
+	t.Helper()
+	select {
+	case change := <-w.Changes:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change received")
+	}
+	return Change{}
+}
+
+func newWatchedStore(t *testing.T) (*Store, *Watcher) {
+	t.Helper()
+	store := NewStore(filepath.Join(t.TempDir(), "settings.json"))
+	require.NoError(t, store.Save(Default()))
+	w, err := store.Watch(testDebounce)
+	require.NoError(t, err)
+	t.Cleanup(func() { w.Close() })
+	return store, w
+}
+
+func TestWatchSeesExternalEdits(t *testing.T) {
+	store, w := newWatchedStore(t)
+
+	require.NoError(t, os.WriteFile(store.Path(), []byte(`{"version":2,"activeProfile":"default","profiles":{"default":{"icon":"cloud","color":"red"}}}`), 0o644))
+	change := nextChange(t, w)
+	require.NoError(t, change.Err)
+	assert.Equal(t, Profile{Icon: "cloud", Color: "red"}, change.Settings.Current())
+
+	s := change.Settings
+	s.SetCurrent(Profile{Icon: "man"})
+	require.NoError(t, store.Save(s), "atomic saves should be seen as well")
+	change = nextChange(t, w)
+	require.NoError(t, change.Err)
+	assert.Equal(t, "man", change.Settings.Current().Icon)
+}
+
+func TestWatchDebouncesPartialWrites(t *testing.T) {
+	store, w := newWatchedStore(t)
+
+	fh, err := os.OpenFile(store.Path(), os.O_WRONLY|os.O_TRUNC, 0o644)
+	require.NoError(t, err)
+	for _, part := range []string{`{"version":2,`, `"profiles":{"default":{"icon":"geometric",`, `"color":""}}}`} {
+		_, err := fh.WriteString(part)
+		require.NoError(t, err)
+		time.Sleep(testDebounce / 4)
+	}
+	require.NoError(t, fh.Close())
+
+	change := nextChange(t, w)
+	require.NoError(t, change.Err, "file should only be read once complete")
+	assert.Equal(t, "geometric", change.Settings.Current().Icon)
+	select {
+	case extra := <-w.Changes:
+		t.Fatalf("expected a single change, got another one: %+v", extra)
+	case <-time.After(3 * testDebounce):
+	}
+}
+
+func TestWatchReportsInvalidFileWithoutRestoring(t *testing.T) {
+	store, w := newWatchedStore(t)
+
+	require.NoError(t, os.WriteFile(store.Path(), []byte(`{"profiles":`), 0o644))
+	change := nextChange(t, w)
+	assert.ErrorIs(t, change.Err, ErrCorrupt)
+
+	data, err := os.ReadFile(store.Path())
+	require.NoError(t, err)
+	assert.Equal(t, `{"profiles":`, string(data), "the user's file must be left alone")
+}
+
+func TestWatchIgnoresOtherFiles(t *testing.T) {
+	store, w := newWatchedStore(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(store.Path()), "other.json"), []byte(`{}`), 0o644))
+	select {
+	case change := <-w.Changes:
+		t.Fatalf("unexpected change: %+v", change)
+	case <-time.After(3 * testDebounce):
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Package event defines the notifications emitted by the mouse mover while it
+runs, and a small fan-out bus so that several consumers (tray, MQTT, ...) can
+follow them independently.
+*/
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// Type of event as defined below
+type Type string
+
+/*
+Started
+Stopped
+Paused
+Resumed
+Moved
+MoveFailed
+Sleep
+Wake
+Idle
+Active
+
+These are the types of events the mouse mover currently emits
+*/
+const (
+	Started    Type = "start"
+	Stopped    Type = "stop"
+	Paused     Type = "pause"
+	Resumed    Type = "resume"
+	Moved      Type = "move"
+	MoveFailed Type = "move-failed"
+	Sleep      Type = "sleep"
+	Wake       Type = "wake"
+	Idle       Type = "idle"
+	Active     Type = "active"
+)
+
+// Types lists every type of event, in the order above
+var Types = []Type{Started, Stopped, Paused, Resumed, Moved, MoveFailed, Sleep, Wake, Idle, Active}
+
+// subscriberBuffer is the number of events a slow subscriber can lag behind
+// before further events are dropped for it
+const subscriberBuffer = 32
+
+// Event is the data packet sent from the mouse mover to its subscribers
+type Event struct {
+	Type            Type
+	Time            time.Time //time the event happened
+	LastMoved       time.Time //last successful movement, zero if none yet
+	DidNotMoveCount int       //consecutive failed movements
+	PausedUntil     time.Time //only set for Paused events
+	Message         string    //optional human readable details
+	// state of the mover once the event happened
+	Running  bool
+	Paused   bool
+	Sleeping bool
+}
+
+// Bus delivers published events to every subscriber
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes
+// and closes the channel; it is safe to call more than once.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mutex.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan Event]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			delete(b.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// Publish sends the event to all subscribers without blocking. Subscribers
+// whose buffer is full miss the event.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
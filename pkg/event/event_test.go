@@ -0,0 +1,63 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishReachesAllSubscribers(t *testing.T) {
+	bus := &Bus{}
+	ch1, unsubscribe1 := bus.Subscribe()
+	defer unsubscribe1()
+	ch2, unsubscribe2 := bus.Subscribe()
+	defer unsubscribe2()
+
+	bus.Publish(Event{Type: Moved})
+
+	for _, ch := range []<-chan Event{ch1, ch2} {
+		select {
+		case e := <-ch:
+			assert.Equal(t, Moved, e.Type)
+			assert.False(t, e.Time.IsZero(), "time should be filled in")
+		case <-time.After(time.Second):
+			t.Fatal("subscriber did not receive the event")
+		}
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	bus := &Bus{}
+	ch, unsubscribe := bus.Subscribe()
+	unsubscribe()
+	unsubscribe() //should be idempotent
+
+	_, ok := <-ch
+	assert.False(t, ok, "channel should be closed")
+	bus.Publish(Event{Type: Started}) //must not panic on closed channel
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	bus := &Bus{}
+	_, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*2; i++ {
+			bus.Publish(Event{Type: Idle})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a full subscriber")
+	}
+}
+
+func TestPublishOnNilBus(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(Event{Type: Stopped}) })
+}
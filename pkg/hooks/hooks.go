@@ -0,0 +1,153 @@
+/*
+Package hooks runs user scripts when the mouse mover changes state, the same
+way git runs the scripts of .git/hooks.
+
+A hook is an executable file named after the event in the hooks directory:
+on-start, on-stop, on-move, on-move-failed, on-sleep, on-wake, and also
+on-pause, on-resume, on-idle and on-active. Missing or non-executable hooks
+are skipped. Hooks run one at a time, in the order of the events, from the
+hooks directory and with the following extra environment variables:
+
+	AMM_EVENT                 event type, e.g. move-failed
+	AMM_TIME                  time of the event (RFC 3339)
+	AMM_LAST_MOVED            last successful movement (RFC 3339), empty if none
+	AMM_DID_NOT_MOVE_COUNT    consecutive failed movements
+	AMM_PAUSED_UNTIL          end of the pause (RFC 3339), only for on-pause
+	AMM_MESSAGE               human readable details, may be empty
+
+A hook still running after the timeout is killed.
+*/
+package hooks
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout is used when the runner is given no timeout
+const DefaultTimeout = 10 * time.Second
+
+const queueSize = 64
+
+// Runner executes the hooks of a directory
+type Runner struct {
+	dir     string
+	timeout time.Duration
+	queue   chan event.Event
+	wg      sync.WaitGroup
+}
+
+// NewRunner starts a runner for the hooks found in dir. The directory is
+// read each time an event happens, so hooks can be added while running.
+func NewRunner(dir string, timeout time.Duration) *Runner {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	r := &Runner{
+		dir:     dir,
+		timeout: timeout,
+		queue:   make(chan event.Event, queueSize),
+	}
+	r.wg.Add(1)
+	go r.runAll()
+	return r
+}
+
+// Name returns the file name of the hook for an event type
+func Name(eventType event.Type) string {
+	return "on-" + string(eventType)
+}
+
+// Handle queues the hook of the event, if any
+func (r *Runner) Handle(e event.Event) {
+	select {
+	case r.queue <- e:
+	default:
+		log.Warnf("hooks: queue full, skipping %v", Name(e.Type))
+	}
+}
+
+// Run forwards every event of the channel to Handle until it is closed
+func (r *Runner) Run(events <-chan event.Event) {
+	for e := range events {
+		r.Handle(e)
+	}
+}
+
+// Close waits for the queued hooks to finish. Handle must not be called
+// afterwards.
+func (r *Runner) Close() {
+	close(r.queue)
+	r.wg.Wait()
+}
+
+func (r *Runner) runAll() {
+	defer r.wg.Done()
+	for e := range r.queue {
+		path := filepath.Join(r.dir, Name(e.Type))
+		if !isExecutable(path) {
+			continue
+		}
+		output, err := r.exec(path, e)
+		if err != nil {
+			log.Errorf("hooks: %v failed: %v: %s", Name(e.Type), err, output)
+		} else {
+			log.Debugf("hooks: %v: %s", Name(e.Type), output)
+		}
+	}
+}
+
+func (r *Runner) exec(path string, e event.Event) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), Env(e)...)
+	//do not wait forever for children that kept stdout open
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = ctx.Err()
+	}
+	return output, err
+}
+
+// Env returns the variables describing the event to a hook
+func Env(e event.Event) []string {
+	return []string{
+		"AMM_EVENT=" + string(e.Type),
+		"AMM_TIME=" + formatTime(e.Time),
+		"AMM_LAST_MOVED=" + formatTime(e.LastMoved),
+		"AMM_DID_NOT_MOVE_COUNT=" + strconv.Itoa(e.DidNotMoveCount),
+		"AMM_PAUSED_UNTIL=" + formatTime(e.PausedUntil),
+		"AMM_MESSAGE=" + e.Message,
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return info.Mode().Perm()&0111 != 0
+}
@@ -0,0 +1,97 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeHook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), mode))
+}
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks tests use shell scripts")
+	}
+}
+
+func TestHookReceivesEventEnvironment(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	writeHook(t, dir, "on-move-failed", `env | grep ^AMM_ | sort > env.txt; pwd > pwd.txt`, 0o755)
+
+	r := NewRunner(dir, time.Second)
+	lastMoved := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	r.Handle(event.Event{
+		Type:            event.MoveFailed,
+		Time:            lastMoved.Add(time.Hour),
+		LastMoved:       lastMoved,
+		DidNotMoveCount: 4,
+		Message:         "cannot move",
+	})
+	r.Close()
+
+	env, err := os.ReadFile(filepath.Join(dir, "env.txt"))
+	require.NoError(t, err, "hook should have run")
+	for _, line := range []string{
+		"AMM_EVENT=move-failed",
+		"AMM_TIME=2024-05-01T10:00:00Z",
+		"AMM_LAST_MOVED=2024-05-01T09:00:00Z",
+		"AMM_DID_NOT_MOVE_COUNT=4",
+		"AMM_MESSAGE=cannot move",
+		"AMM_PAUSED_UNTIL=",
+	} {
+		assert.Contains(t, strings.Split(string(env), "\n"), line)
+	}
+	pwd, err := os.ReadFile(filepath.Join(dir, "pwd.txt"))
+	require.NoError(t, err)
+	resolved, _ := filepath.EvalSymlinks(dir)
+	assert.Equal(t, resolved, strings.TrimSpace(string(pwd)), "hook should run from the hooks directory")
+}
+
+func TestHooksRunInOrderAndOnlyWhenExecutable(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	writeHook(t, dir, "on-start", `echo start >> log.txt`, 0o755)
+	writeHook(t, dir, "on-stop", `echo stop >> log.txt`, 0o755)
+	writeHook(t, dir, "on-sleep", `echo sleep >> log.txt`, 0o644)
+
+	r := NewRunner(dir, time.Second)
+	for _, eventType := range []event.Type{event.Started, event.Sleep, event.Moved, event.Stopped} {
+		r.Handle(event.Event{Type: eventType})
+	}
+	r.Close()
+
+	log, err := os.ReadFile(filepath.Join(dir, "log.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "start\nstop\n", string(log))
+}
+
+func TestHookTimeout(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	writeHook(t, dir, "on-wake", `sleep 5`, 0o755)
+
+	r := NewRunner(dir, 100*time.Millisecond)
+	path := filepath.Join(dir, Name(event.Wake))
+	begin := time.Now()
+	_, err := r.exec(path, event.Event{Type: event.Wake})
+	r.Close()
+
+	assert.Error(t, err, "hook should be killed")
+	assert.Less(t, time.Since(begin), 3*time.Second)
+}
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "on-move-failed", Name(event.MoveFailed))
+	assert.Equal(t, "on-start", Name(event.Started))
+}
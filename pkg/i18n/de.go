@@ -0,0 +1,61 @@
+package i18n
+
+var de = Catalog{
+	"menu.about":                "Über AMM",
+	"menu.about.tooltip":        "Informationen über die App",
+	"menu.start":                "Starten",
+	"menu.start.tooltip":        "die App starten",
+	"menu.stop":                 "Stoppen",
+	"menu.stop.tooltip":         "die App stoppen",
+	"menu.startAtLogin":         "Bei Anmeldung starten",
+	"menu.startAtLogin.tooltip": "AMM bei der Anmeldung starten",
+	"menu.quit":                 "Beenden",
+	"menu.quit.tooltip":         "Die ganze App beenden",
+	"menu.profile":              "Profil",
+	"menu.profile.tooltip":      "Profil der App",
+	"menu.profile.switch":       "Zum Profil %v wechseln",
+	"menu.icons":                "Symbole",
+	"menu.icons.tooltip":        "Symbol der App",
+	"menu.icons.bundled":        "Symbol %v",
+	"menu.icons.custom":         "Symbol aus %v",
+	"menu.icons.theme":          "Design aus %v",
+	"menu.icons.themeBy":        "Design %v von %v",
+	"menu.colors":               "Symbolfarben",
+	"icon.mouse":                "Maus",
+	"icon.cloud":                "Wolke",
+	"icon.geometric":            "Geometrisch",
+	"icon.man":                  "Männchen",
+	"color.system":              "System",
+	"color.system.tooltip":      "Standardfarbe des Systems",
+	"color.blue":                "Blau 🔵",
+	"color.white":               "Weiß ⚪️",
+	"color.red":                 "Rot 🔴",
+	"settings.notSaved":         "Einstellungen nicht gespeichert",
+	"settings.unreadable":       "Einstellungen unlesbar, Standardwerte werden verwendet",
+	"settings.unavailable":      "Einstellungen nicht verfügbar, Standardwerte werden verwendet",
+	"settings.restored":         "Einstellungen aus der Sicherung wiederhergestellt",
+	"settings.invalid":          "Einstellungsdatei ungültig, nicht übernommen",
+	"settings.policyInvalid":    "Systemrichtlinie ungültig, nicht übernommen",
+	"settings.autostartFailed":  "Start bei Anmeldung nicht geändert",
+	"status.state":              "Status: %v",
+	"status.stopped":            "gestoppt",
+	"status.sleeping":           "System im Ruhezustand",
+	"status.pausedUntil":        "pausiert bis %v",
+	"status.running":            "läuft",
+	"status.lastMoved":          "Zuletzt bewegt: %v",
+	"status.never":              "nie",
+	"status.movesToday":         "Bewegungen heute: %d",
+	"status.failures":           "Fehlschläge in Folge: %d",
+	"status.idle":               "Inaktiv seit: %v",
+	"tray.stopped":              "AMM: gestoppt",
+	"tray.sleeping":             "AMM: System im Ruhezustand",
+	"tray.paused":               "AMM: pausiert, geht weiter in %v",
+	"tray.running":              "AMM: läuft",
+	"tray.nextMove":             "AMM: läuft, nächste Bewegung in %v",
+	"about.title":               "Automatic-mouse-mover App %v",
+	"about.text":                "Erstellt von Prashant Gupta. \n\nMehr Informationen unter: %v",
+	"about.build":               "Build: %v",
+	"about.ok":                  "OK",
+	"alert.moveFailed.title":    "Fehler bei Automatic Mouse Mover",
+	"alert.moveFailed":          "Der Mauszeiger kann um %v nicht bewegt werden. Zuletzt bewegt um %v. %v Mal passiert. (Nur eine Benachrichtigung alle 24 Stunden.) Details im README.",
+}
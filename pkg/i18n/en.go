@@ -0,0 +1,61 @@
+package i18n
+
+var en = Catalog{
+	"menu.about":                "About AMM",
+	"menu.about.tooltip":        "Information about the app",
+	"menu.start":                "Start",
+	"menu.start.tooltip":        "start the app",
+	"menu.stop":                 "Stop",
+	"menu.stop.tooltip":         "stop the app",
+	"menu.startAtLogin":         "Start at login",
+	"menu.startAtLogin.tooltip": "Start AMM when you log in",
+	"menu.quit":                 "Quit",
+	"menu.quit.tooltip":         "Quit the whole app",
+	"menu.profile":              "Profile",
+	"menu.profile.tooltip":      "profile of the app",
+	"menu.profile.switch":       "Switch to the %v profile",
+	"menu.icons":                "Icons",
+	"menu.icons.tooltip":        "icon of the app",
+	"menu.icons.bundled":        "%v icon",
+	"menu.icons.custom":         "Icon from %v",
+	"menu.icons.theme":          "Theme from %v",
+	"menu.icons.themeBy":        "%v theme by %v",
+	"menu.colors":               "Icon Colors",
+	"icon.mouse":                "Mouse",
+	"icon.cloud":                "Cloud",
+	"icon.geometric":            "Geometric",
+	"icon.man":                  "Man",
+	"color.system":              "System",
+	"color.system.tooltip":      "System default color",
+	"color.blue":                "Blue 🔵",
+	"color.white":               "White ⚪️",
+	"color.red":                 "Red 🔴",
+	"settings.notSaved":         "Settings not saved",
+	"settings.unreadable":       "Settings unreadable, using defaults",
+	"settings.unavailable":      "Settings unavailable, using defaults",
+	"settings.restored":         "Settings restored from backup",
+	"settings.invalid":          "Settings file invalid, not applied",
+	"settings.policyInvalid":    "System policy invalid, not applied",
+	"settings.autostartFailed":  "Start at login not changed",
+	"status.state":              "State: %v",
+	"status.stopped":            "stopped",
+	"status.sleeping":           "system sleeping",
+	"status.pausedUntil":        "paused until %v",
+	"status.running":            "running",
+	"status.lastMoved":          "Last moved: %v",
+	"status.never":              "never",
+	"status.movesToday":         "Moves today: %d",
+	"status.failures":           "Consecutive failures: %d",
+	"status.idle":               "Idle for: %v",
+	"tray.stopped":              "AMM: stopped",
+	"tray.sleeping":             "AMM: system sleeping",
+	"tray.paused":               "AMM: paused, resumes in %v",
+	"tray.running":              "AMM: running",
+	"tray.nextMove":             "AMM: running, next move in %v",
+	"about.title":               "Automatic-mouse-mover app %v",
+	"about.text":                "Created by Prashant Gupta. \n\nMore info at: %v",
+	"about.build":               "Build: %v",
+	"about.ok":                  "OK",
+	"alert.moveFailed.title":    "Error with Automatic Mouse Mover",
+	"alert.moveFailed":          "Mouse pointer cannot be moved at %v. Last moved at %v. Happened %v times. (Only notifies once every 24 hours.) See README for details.",
+}
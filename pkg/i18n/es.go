@@ -0,0 +1,61 @@
+package i18n
+
+var es = Catalog{
+	"menu.about":                "Acerca de AMM",
+	"menu.about.tooltip":        "Información sobre la aplicación",
+	"menu.start":                "Iniciar",
+	"menu.start.tooltip":        "iniciar la aplicación",
+	"menu.stop":                 "Detener",
+	"menu.stop.tooltip":         "detener la aplicación",
+	"menu.startAtLogin":         "Iniciar al iniciar sesión",
+	"menu.startAtLogin.tooltip": "Iniciar AMM al iniciar sesión",
+	"menu.quit":                 "Salir",
+	"menu.quit.tooltip":         "Salir de la aplicación",
+	"menu.profile":              "Perfil",
+	"menu.profile.tooltip":      "perfil de la aplicación",
+	"menu.profile.switch":       "Cambiar al perfil %v",
+	"menu.icons":                "Iconos",
+	"menu.icons.tooltip":        "icono de la aplicación",
+	"menu.icons.bundled":        "Icono %v",
+	"menu.icons.custom":         "Icono de %v",
+	"menu.icons.theme":          "Tema de %v",
+	"menu.icons.themeBy":        "Tema %v de %v",
+	"menu.colors":               "Colores del icono",
+	"icon.mouse":                "Ratón",
+	"icon.cloud":                "Nube",
+	"icon.geometric":            "Geométrico",
+	"icon.man":                  "Muñeco",
+	"color.system":              "Sistema",
+	"color.system.tooltip":      "Color predeterminado del sistema",
+	"color.blue":                "Azul 🔵",
+	"color.white":               "Blanco ⚪️",
+	"color.red":                 "Rojo 🔴",
+	"settings.notSaved":         "Ajustes no guardados",
+	"settings.unreadable":       "Ajustes ilegibles, se usan los valores predeterminados",
+	"settings.unavailable":      "Ajustes no disponibles, se usan los valores predeterminados",
+	"settings.restored":         "Ajustes restaurados desde la copia de seguridad",
+	"settings.invalid":          "Archivo de ajustes no válido, no aplicado",
+	"settings.policyInvalid":    "Política del sistema no válida, no aplicada",
+	"settings.autostartFailed":  "Inicio al iniciar sesión no modificado",
+	"status.state":              "Estado: %v",
+	"status.stopped":            "detenido",
+	"status.sleeping":           "sistema en suspensión",
+	"status.pausedUntil":        "en pausa hasta las %v",
+	"status.running":            "en marcha",
+	"status.lastMoved":          "Último movimiento: %v",
+	"status.never":              "nunca",
+	"status.movesToday":         "Movimientos hoy: %d",
+	"status.failures":           "Fallos consecutivos: %d",
+	"status.idle":               "Inactivo desde hace: %v",
+	"tray.stopped":              "AMM: detenido",
+	"tray.sleeping":             "AMM: sistema en suspensión",
+	"tray.paused":               "AMM: en pausa, se reanuda en %v",
+	"tray.running":              "AMM: en marcha",
+	"tray.nextMove":             "AMM: en marcha, próximo movimiento en %v",
+	"about.title":               "Aplicación Automatic-mouse-mover %v",
+	"about.text":                "Creada por Prashant Gupta. \n\nMás información en: %v",
+	"about.build":               "Compilación: %v",
+	"about.ok":                  "Aceptar",
+	"alert.moveFailed.title":    "Error de Automatic Mouse Mover",
+	"alert.moveFailed":          "No se puede mover el puntero del ratón a las %v. Último movimiento a las %v. Ha ocurrido %v veces. (Solo se notifica una vez cada 24 horas.) Consulte el README para más detalles.",
+}
@@ -0,0 +1,61 @@
+package i18n
+
+var fr = Catalog{
+	"menu.about":                "À propos d'AMM",
+	"menu.about.tooltip":        "Informations sur l'application",
+	"menu.start":                "Démarrer",
+	"menu.start.tooltip":        "démarrer l'application",
+	"menu.stop":                 "Arrêter",
+	"menu.stop.tooltip":         "arrêter l'application",
+	"menu.startAtLogin":         "Lancer à la connexion",
+	"menu.startAtLogin.tooltip": "Lancer AMM à l'ouverture de la session",
+	"menu.quit":                 "Quitter",
+	"menu.quit.tooltip":         "Quitter complètement l'application",
+	"menu.profile":              "Profil",
+	"menu.profile.tooltip":      "profil de l'application",
+	"menu.profile.switch":       "Passer au profil %v",
+	"menu.icons":                "Icônes",
+	"menu.icons.tooltip":        "icône de l'application",
+	"menu.icons.bundled":        "Icône %v",
+	"menu.icons.custom":         "Icône de %v",
+	"menu.icons.theme":          "Thème de %v",
+	"menu.icons.themeBy":        "Thème %v par %v",
+	"menu.colors":               "Couleurs de l'icône",
+	"icon.mouse":                "Souris",
+	"icon.cloud":                "Nuage",
+	"icon.geometric":            "Géométrique",
+	"icon.man":                  "Bonhomme",
+	"color.system":              "Système",
+	"color.system.tooltip":      "Couleur par défaut du système",
+	"color.blue":                "Bleu 🔵",
+	"color.white":               "Blanc ⚪️",
+	"color.red":                 "Rouge 🔴",
+	"settings.notSaved":         "Réglages non enregistrés",
+	"settings.unreadable":       "Réglages illisibles, valeurs par défaut utilisées",
+	"settings.unavailable":      "Réglages indisponibles, valeurs par défaut utilisées",
+	"settings.restored":         "Réglages restaurés depuis la sauvegarde",
+	"settings.invalid":          "Fichier de réglages invalide, non appliqué",
+	"settings.policyInvalid":    "Politique système invalide, non appliquée",
+	"settings.autostartFailed":  "Lancement à la connexion non modifié",
+	"status.state":              "État : %v",
+	"status.stopped":            "arrêté",
+	"status.sleeping":           "système en veille",
+	"status.pausedUntil":        "en pause jusqu'à %v",
+	"status.running":            "en marche",
+	"status.lastMoved":          "Dernier mouvement : %v",
+	"status.never":              "jamais",
+	"status.movesToday":         "Mouvements aujourd'hui : %d",
+	"status.failures":           "Échecs consécutifs : %d",
+	"status.idle":               "Inactif depuis : %v",
+	"tray.stopped":              "AMM : arrêté",
+	"tray.sleeping":             "AMM : système en veille",
+	"tray.paused":               "AMM : en pause, reprend dans %v",
+	"tray.running":              "AMM : en marche",
+	"tray.nextMove":             "AMM : en marche, prochain mouvement dans %v",
+	"about.title":               "Application Automatic-mouse-mover %v",
+	"about.text":                "Créée par Prashant Gupta. \n\nPlus d'informations sur : %v",
+	"about.build":               "Compilation : %v",
+	"about.ok":                  "OK",
+	"alert.moveFailed.title":    "Erreur d'Automatic Mouse Mover",
+	"alert.moveFailed":          "Impossible de déplacer le pointeur de la souris à %v. Dernier mouvement à %v. Arrivé %v fois. (Une seule notification toutes les 24 heures.) Voir le README pour plus de détails.",
+}
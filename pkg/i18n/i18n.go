@@ -0,0 +1,101 @@
+/*
+Package i18n translates the texts shown in the tray menu and the alerts.
+
+Each language has a catalog of messages, keyed by an identifier such as
+menu.start. Messages are fmt formats, their arguments being given in the
+same order in every language. A message missing from a catalog is shown in
+English.
+
+The language is the one set by the settings, or else the one of the locale
+environment: LC_ALL, LC_MESSAGES, then LANG. Logs stay in English.
+*/
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fallback is the language of the messages missing from a catalog
+const Fallback = "en"
+
+// Catalog holds the messages of a language
+type Catalog map[string]string
+
+// catalogs by language code
+var catalogs = map[string]Catalog{
+	"en": en,
+	"fr": fr,
+	"de": de,
+	"es": es,
+}
+
+// Languages are the codes of the languages having a catalog
+var Languages = []string{"en", "fr", "de", "es"}
+
+var (
+	mutex    sync.RWMutex
+	language = Fallback
+)
+
+// Supported tells if the language has a catalog
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Detect returns the language of the locale environment, e.g. fr for
+// fr_FR.UTF-8, the fallback if it has no catalog
+func Detect(getenv func(string) string) string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		locale := getenv(name)
+		if locale == "" {
+			continue
+		}
+		//the first variable set wins, even with an unknown language
+		lang := strings.ToLower(locale)
+		if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+			lang = lang[:i]
+		}
+		if Supported(lang) {
+			return lang
+		}
+		return Fallback
+	}
+	return Fallback
+}
+
+// SetLanguage sets the language of the messages, the one of the locale
+// environment being used when lang is empty
+func SetLanguage(lang string, getenv func(string) string) {
+	if lang == "" || !Supported(lang) {
+		lang = Detect(getenv)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	language = lang
+}
+
+// Language returns the language of the messages
+func Language() string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return language
+}
+
+// T returns the message of the key in the current language, formatted
+// with the arguments
+func T(key string, args ...interface{}) string {
+	message, ok := catalogs[Language()][key]
+	if !ok {
+		message, ok = catalogs[Fallback][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
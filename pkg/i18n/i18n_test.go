@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var verbs = regexp.MustCompile(`%[a-z]`)
+
+func TestCatalogsCoverAllKeys(t *testing.T) {
+	assert.Len(t, catalogs, len(Languages), "every language needs a catalog")
+	for _, lang := range Languages {
+		catalog, ok := catalogs[lang]
+		if !assert.True(t, ok, "no catalog for %v", lang) {
+			continue
+		}
+		for key, message := range catalogs[Fallback] {
+			translated, ok := catalog[key]
+			if !assert.True(t, ok, "%v misses %v", lang, key) {
+				continue
+			}
+			assert.NotEmpty(t, translated, "%v: %v", lang, key)
+			//the arguments are given in the same order in every language
+			assert.Equal(t, verbs.FindAllString(message, -1), verbs.FindAllString(translated, -1), "%v: %v", lang, key)
+		}
+		var extra []string
+		for key := range catalog {
+			if _, ok := catalogs[Fallback][key]; !ok {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(extra)
+		assert.Empty(t, extra, "%v has keys English does not have", lang)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, test := range []struct {
+		env  map[string]string
+		want string
+	}{
+		{nil, "en"},
+		{map[string]string{"LANG": "fr_FR.UTF-8"}, "fr"},
+		{map[string]string{"LANG": "fr_FR.UTF-8", "LC_MESSAGES": "de_DE"}, "de"},
+		{map[string]string{"LANG": "fr_FR.UTF-8", "LC_MESSAGES": "de_DE", "LC_ALL": "es_ES@euro"}, "es"},
+		{map[string]string{"LANG": "fr_FR.UTF-8", "LC_MESSAGES": "C"}, "en"},
+		{map[string]string{"LANG": "ja_JP.UTF-8"}, "en"},
+		{map[string]string{"LANG": "DE"}, "de"},
+	} {
+		got := Detect(func(name string) string { return test.env[name] })
+		assert.Equal(t, test.want, got, "%v", test.env)
+	}
+}
+
+func TestT(t *testing.T) {
+	t.Cleanup(func() { SetLanguage(Fallback, nil) })
+	env := func(name string) string {
+		if name == "LANG" {
+			return "de_DE.UTF-8"
+		}
+		return ""
+	}
+
+	SetLanguage("fr", env)
+	assert.Equal(t, "fr", Language())
+	assert.Equal(t, "Démarrer", T("menu.start"))
+	assert.Equal(t, "Mouvements aujourd'hui : 3", T("status.movesToday", 3))
+
+	SetLanguage("", env)
+	assert.Equal(t, "de", Language(), "the locale is used without a language")
+	SetLanguage("xx", env)
+	assert.Equal(t, "de", Language(), "the locale is used for unknown languages")
+
+	assert.Equal(t, "no.such.key", T("no.such.key"))
+	quit := de["menu.quit"]
+	delete(de, "menu.quit")
+	defer func() { de["menu.quit"] = quit }()
+	assert.Equal(t, "Quit", T("menu.quit"), "missing messages are shown in English")
+}
@@ -0,0 +1,74 @@
+package icons
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+)
+
+// nudge is the offset of each frame of the move animation, in steps of
+// 1/16 of the icon size
+var nudge = []int{1, 2, 1, 0, -1, -2, -1, 0}
+
+// MoveFrames returns the frames of a short animation of the icon nudged
+// diagonally, as the mouse is. The icon is rendered in the style first, the
+// badge staying in place.
+func MoveFrames(src image.Image, style Style) []*image.NRGBA {
+	badge := style.Badge
+	style.Badge = NoBadge
+	base := Render(src, style)
+	step := base.Bounds().Dx() / 16
+	if step < 1 {
+		step = 1
+	}
+	frames := make([]*image.NRGBA, len(nudge))
+	for i, offset := range nudge {
+		frames[i] = shift(base, offset*step, offset*step)
+		drawBadge(frames[i], badge)
+	}
+	return frames
+}
+
+// MoveFramesPNG decodes a PNG image and returns the frames of its move
+// animation encoded as PNG
+func MoveFramesPNG(data []byte, style Style) ([][]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var encoded [][]byte
+	for _, frame := range MoveFrames(img, style) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, frame); err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, buf.Bytes())
+	}
+	return encoded, nil
+}
+
+// shift returns a copy of the image moved by dx and dy pixels, what goes
+// past the edges being cut
+func shift(img *image.NRGBA, dx, dy int) *image.NRGBA {
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	width := bounds.Dx()
+	for y := 0; y < bounds.Dy(); y++ {
+		sy := y - dy
+		if sy < 0 || sy >= bounds.Dy() {
+			continue
+		}
+		from, to := 0, width
+		if dx > 0 {
+			to = width - dx
+		} else {
+			from = -dx
+		}
+		if from >= to {
+			continue
+		}
+		src := img.Pix[sy*img.Stride+from*4 : sy*img.Stride+to*4]
+		copy(out.Pix[y*out.Stride+(from+dx)*4:], src)
+	}
+	return out
+}
@@ -0,0 +1,35 @@
+package icons
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveFrames(t *testing.T) {
+	icon := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	icon.SetNRGBA(10, 10, color.NRGBA{100, 100, 100, 255})
+	blue := color.NRGBA{30, 144, 255, 255}
+
+	frames := MoveFrames(icon, Style{Color: &blue, Badge: ErrorBadge})
+	require.Len(t, frames, len(nudge))
+	assert.Equal(t, blue, frames[0].NRGBAAt(12, 12), "nudged by 1/16 of the size, and tinted")
+	assert.Equal(t, blue, frames[1].NRGBAAt(14, 14))
+	assert.Equal(t, blue, frames[5].NRGBAAt(6, 6))
+	assert.Equal(t, blue, frames[len(frames)-1].NRGBAAt(10, 10), "ends where it started")
+	for i, frame := range frames {
+		assert.Equal(t, badgeRed, frame.NRGBAAt(24, 24), "frame %d: the badge stays in place", i)
+	}
+}
+
+func TestShiftCutsTheEdges(t *testing.T) {
+	img := solid(4, color.NRGBA{1, 2, 3, 255})
+	shifted := shift(img, -2, 1)
+	assert.Equal(t, color.NRGBA{}, shifted.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{1, 2, 3, 255}, shifted.NRGBAAt(1, 1))
+	assert.Equal(t, color.NRGBA{}, shifted.NRGBAAt(2, 1))
+	assert.Equal(t, color.NRGBA{}, shift(img, 4, 0).NRGBAAt(3, 3))
+}
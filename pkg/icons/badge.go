@@ -0,0 +1,151 @@
+package icons
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Badge is a small glyph drawn in the bottom right corner of the icon to
+// show the state of the app
+type Badge string
+
+const (
+	NoBadge    Badge = ""
+	PauseBadge Badge = "pause" //the mouse is paused
+	ErrorBadge Badge = "error" //the last moves failed
+	ClockBadge Badge = "clock" //the mouse only moves within time limits
+	MoonBadge  Badge = "moon"  //the system is sleeping
+)
+
+// badgeScale is the size of a badge relative to the icon
+const badgeScale = 0.5
+
+// samples is the number of subpixels per side used to smooth the edges
+const samples = 4
+
+var (
+	badgeDark  = color.NRGBA{40, 40, 40, 255}
+	badgeLight = color.NRGBA{255, 255, 255, 255}
+	badgeRed   = color.NRGBA{255, 59, 48, 255}
+	badgeMoon  = color.NRGBA{255, 204, 0, 255}
+)
+
+// layer is a shape of a badge, in coordinates from 0 to 1
+type layer struct {
+	inside func(x, y float64) bool
+	color  color.NRGBA
+}
+
+func circle(cx, cy, r float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		return (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r
+	}
+}
+
+func rect(x0, y0, x1, y1 float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		return x >= x0 && x <= x1 && y >= y0 && y <= y1
+	}
+}
+
+// segment is a thick line from (x0, y0) to (x1, y1)
+func segment(x0, y0, x1, y1, width float64) func(x, y float64) bool {
+	return func(x, y float64) bool {
+		dx, dy := x1-x0, y1-y0
+		t := math.Max(0, math.Min(1, ((x-x0)*dx+(y-y0)*dy)/(dx*dx+dy*dy)))
+		px, py := x0+t*dx-x, y0+t*dy-y
+		return px*px+py*py <= width*width/4
+	}
+}
+
+// badgeLayers are drawn in order, over a transparent cut out of the icon
+var badgeLayers = map[Badge][]layer{
+	PauseBadge: {
+		{circle(0.5, 0.5, 0.5), badgeDark},
+		{rect(0.3, 0.25, 0.43, 0.75), badgeLight},
+		{rect(0.57, 0.25, 0.7, 0.75), badgeLight},
+	},
+	ErrorBadge: {
+		{circle(0.5, 0.5, 0.45), badgeRed},
+	},
+	ClockBadge: {
+		{circle(0.5, 0.5, 0.5), badgeDark},
+		{circle(0.5, 0.5, 0.38), badgeLight},
+		{segment(0.5, 0.5, 0.5, 0.22, 0.1), badgeDark},
+		{segment(0.5, 0.5, 0.7, 0.5, 0.1), badgeDark},
+	},
+	MoonBadge: {
+		{func(x, y float64) bool {
+			return circle(0.5, 0.5, 0.45)(x, y) && !circle(0.72, 0.32, 0.36)(x, y)
+		}, badgeMoon},
+	},
+}
+
+// drawBadge draws the badge over the bottom right corner of the image. The
+// icon is cleared around the badge so that it stays readable.
+func drawBadge(img *image.NRGBA, badge Badge) {
+	layers, ok := badgeLayers[badge]
+	if !ok {
+		return
+	}
+	bounds := img.Bounds()
+	size := int(math.Round(float64(bounds.Dx()) * badgeScale))
+	if h := int(math.Round(float64(bounds.Dy()) * badgeScale)); h < size {
+		size = h
+	}
+	if size < 4 {
+		return
+	}
+	gap := float64(size) / 8 //cleared around the badge
+	left, top := bounds.Max.X-size, bounds.Max.Y-size
+	cutout := circle(0.5, 0.5, 0.5+gap/float64(size))
+
+	for py := top - int(gap) - 1; py < bounds.Max.Y; py++ {
+		for px := left - int(gap) - 1; px < bounds.Max.X; px++ {
+			if !(image.Point{px, py}.In(bounds)) {
+				continue
+			}
+			i := img.PixOffset(px, py)
+			p := img.Pix[i : i+4 : i+4]
+			//clear the icon behind the badge and its gap
+			cleared := coverage(cutout, px-left, py-top, size)
+			p[3] = uint8(float64(p[3])*(1-cleared) + 0.5)
+			for _, l := range layers {
+				if c := coverage(l.inside, px-left, py-top, size); c > 0 {
+					over(p, l.color, c)
+				}
+			}
+		}
+	}
+}
+
+// coverage is the part of the pixel inside the shape, from 0 to 1
+func coverage(inside func(x, y float64) bool, px, py, size int) float64 {
+	in := 0
+	for sy := 0; sy < samples; sy++ {
+		for sx := 0; sx < samples; sx++ {
+			x := (float64(px) + (float64(sx)+0.5)/samples) / float64(size)
+			y := (float64(py) + (float64(sy)+0.5)/samples) / float64(size)
+			if inside(x, y) {
+				in++
+			}
+		}
+	}
+	return float64(in) / (samples * samples)
+}
+
+// over composites the color with the given coverage over a non-premultiplied
+// pixel
+func over(p []uint8, c color.NRGBA, cover float64) {
+	srcA := float64(c.A) / 255 * cover
+	dstA := float64(p[3]) / 255
+	outA := srcA + dstA*(1-srcA)
+	if outA == 0 {
+		return
+	}
+	for i, src := range []uint8{c.R, c.G, c.B} {
+		p[i] = uint8((float64(src)*srcA+float64(p[i])*dstA*(1-srcA))/outA + 0.5)
+	}
+	p[3] = uint8(outA*255 + 0.5)
+}
@@ -0,0 +1,50 @@
+package icons
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// solid is an opaque icon of one color
+func solid(size int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestBadges(t *testing.T) {
+	gray := color.NRGBA{128, 128, 128, 255}
+	blue := color.NRGBA{30, 144, 255, 255}
+	for _, badge := range []Badge{PauseBadge, ErrorBadge, ClockBadge, MoonBadge} {
+		img := Render(solid(32, gray), Style{Color: &blue, Badge: badge})
+		assert.Equal(t, blue, img.NRGBAAt(4, 4), "%v: the rest of the icon is tinted", badge)
+		assert.NotEqual(t, blue, img.NRGBAAt(24, 24), "%v: the badge is in the bottom right corner", badge)
+	}
+
+	img := Render(solid(32, gray), Style{Badge: ErrorBadge})
+	assert.Equal(t, badgeRed, img.NRGBAAt(24, 24), "the error badge is a red dot")
+	assert.Less(t, img.NRGBAAt(20, 15).A, uint8(255), "the icon is cleared around the badge")
+
+	img = Render(solid(32, gray), Style{Badge: PauseBadge})
+	assert.Equal(t, badgeLight, img.NRGBAAt(21, 24), "first bar of the pause glyph")
+	assert.Equal(t, badgeDark, img.NRGBAAt(24, 24), "between the bars")
+
+	assert.Equal(t, solid(32, gray).Pix, Render(solid(32, gray), Style{}).Pix, "no badge by default")
+	assert.Equal(t, solid(4, gray).Pix, Render(solid(4, gray), Style{Badge: ErrorBadge}).Pix, "too small for a badge")
+}
+
+func TestBadgeEdgesAreSmooth(t *testing.T) {
+	img := Render(image.NewNRGBA(image.Rect(0, 0, 64, 64)), Style{Badge: ErrorBadge})
+	partial := 0
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] > 0 && img.Pix[i] < 255 {
+			partial++
+		}
+	}
+	assert.Greater(t, partial, 10, "the edge of the dot should be anti-aliased")
+}
@@ -0,0 +1,57 @@
+package icons
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// Named are the colors that can be given by name instead of a hex value
+var Named = map[string]color.NRGBA{
+	"blue":  {30, 144, 255, 255},
+	"white": {255, 255, 255, 255},
+	"red":   {255, 0, 0, 255},
+}
+
+// ParseColor reads a color name or a #RRGGBB or #RRGGBBAA hex value
+func ParseColor(s string) (color.NRGBA, error) {
+	if c, ok := Named[s]; ok {
+		return c, nil
+	}
+	invalid := fmt.Errorf("%q is not blue, white, red or a #RRGGBB or #RRGGBBAA value", s)
+	hex := strings.TrimPrefix(s, "#")
+	if hex == s || (len(hex) != 6 && len(hex) != 8) {
+		return color.NRGBA{}, invalid
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, invalid
+	}
+	return color.NRGBA{uint8(value >> 24), uint8(value >> 16), uint8(value >> 8), uint8(value)}, nil
+}
+
+// Hex formats a color as #RRGGBB, or #RRGGBBAA if it is translucent
+func Hex(c color.NRGBA) string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Swatch returns a PNG image of a square filled with the color, for menus
+func Swatch(c color.NRGBA, size int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img) //cannot fail when writing to memory
+	return buf.Bytes()
+}
@@ -0,0 +1,36 @@
+package icons
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseColor(t *testing.T) {
+	for s, want := range map[string]color.NRGBA{
+		"red":       {255, 0, 0, 255},
+		"#1e90ff":   {30, 144, 255, 255},
+		"#1E90FF80": {30, 144, 255, 128},
+	} {
+		c, err := ParseColor(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, c, s)
+	}
+	for _, s := range []string{"", "green", "1e90ff", "#1e90f", "#1e90ffzz", "#+1e90ff"} {
+		_, err := ParseColor(s)
+		assert.Error(t, err, s)
+	}
+	assert.Equal(t, "#1e90ff", Hex(color.NRGBA{30, 144, 255, 255}))
+	assert.Equal(t, "#1e90ff80", Hex(color.NRGBA{30, 144, 255, 128}))
+}
+
+func TestSwatch(t *testing.T) {
+	img, err := png.Decode(bytes.NewReader(Swatch(color.NRGBA{30, 144, 255, 255}, 16)))
+	require.NoError(t, err)
+	assert.Equal(t, 16, img.Bounds().Dx())
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(8, 8)), color.NRGBA{30, 144, 255, 255})
+}
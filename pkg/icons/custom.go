@@ -0,0 +1,204 @@
+/*
+Package icons handles the icons shown in the tray.
+
+Besides the bundled icons, users can drop their own PNG images in the icons
+folder of the config directory. A custom icon is stored in the settings by
+its file name, e.g. rocket.png, which tells it apart from the bundled icons.
+
+The bundled icons may be SVG files or PNG files with @2x and @3x variants,
+from which the source best fitting the display is picked.
+
+Themes are directories holding a theme.json manifest and an icon for each
+state of the mouse mover. They are stored in the settings as theme:<dir>.
+*/
+package icons
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Sizes accepted for custom icons, in pixels
+const (
+	MinSize = 16
+	MaxSize = 1024
+)
+
+// MaxFileSize is the largest custom icon file accepted, in bytes
+const MaxFileSize = 1 << 20
+
+// Custom is a valid icon of the user icons directory
+type Custom struct {
+	Name string //file name, as stored in the settings
+	Path string
+	Data []byte
+}
+
+// IsCustom tells if an icon name refers to a file of the icons directory
+// rather than a bundled icon
+func IsCustom(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".png")
+}
+
+// ValidateName checks that a custom icon name is a plain PNG file name
+func ValidateName(name string) error {
+	if !IsCustom(name) || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%q is not the name of a PNG file of the icons folder", name)
+	}
+	return nil
+}
+
+// Validate checks that data is a complete PNG image of an acceptable size
+func Validate(data []byte) error {
+	if len(data) > MaxFileSize {
+		return fmt.Errorf("file larger than %d KB", MaxFileSize>>10)
+	}
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errors.New("not a PNG image")
+	}
+	for _, size := range []int{config.Width, config.Height} {
+		if size < MinSize || size > MaxSize {
+			return fmt.Errorf("%dx%d pixels, expected between %d and %d", config.Width, config.Height, MinSize, MaxSize)
+		}
+	}
+	//the header alone says nothing of the pixels, which may be truncated
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("damaged PNG image: %w", err)
+	}
+	return nil
+}
+
+// Load reads and checks the custom icon of the directory
+func Load(dir, name string) (Custom, error) {
+	icon := Custom{Name: name, Path: filepath.Join(dir, name)}
+	if err := ValidateName(name); err != nil {
+		return icon, err
+	}
+	info, err := os.Stat(icon.Path)
+	if err != nil {
+		return icon, err
+	}
+	if info.Size() > MaxFileSize {
+		return icon, fmt.Errorf("%v: file larger than %d KB", icon.Path, MaxFileSize>>10)
+	}
+	icon.Data, err = os.ReadFile(icon.Path)
+	if err != nil {
+		return icon, err
+	}
+	if err := Validate(icon.Data); err != nil {
+		return icon, fmt.Errorf("%v: %w", icon.Path, err)
+	}
+	return icon, nil
+}
+
+// Scan returns the valid icons of the directory sorted by name, and an
+// error for each invalid one. A missing directory has no icons.
+func Scan(dir string) ([]Custom, []error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, []error{err}
+	}
+	var found []Custom
+	var problems []error
+	for _, entry := range entries {
+		if entry.IsDir() || ValidateName(entry.Name()) != nil {
+			continue
+		}
+		icon, err := Load(dir, entry.Name())
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		found = append(found, icon)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+	return found, problems
+}
+
+// Watcher tells when the icons of a directory change
+type Watcher struct {
+	// Changes receives a value once the directory stopped changing
+	Changes <-chan struct{}
+
+	dir     string
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+// Watch follows the directory, creating it if needed. Copying several
+// icons is reported once, after nothing changed for the debounce duration.
+func Watch(dir string, debounce time.Duration) (*Watcher, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	//themes are subdirectories, their manifest and icons are followed too
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			watcher.Add(filepath.Join(dir, entry.Name()))
+		}
+	}
+	changes := make(chan struct{})
+	w := &Watcher{Changes: changes, dir: filepath.Clean(dir), watcher: watcher, done: make(chan struct{})}
+	go w.run(debounce, changes)
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.watcher.Close()
+}
+
+func (w *Watcher) run(debounce time.Duration, changes chan<- struct{}) {
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case e, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if e.Has(fsnotify.Create) {
+				if info, err := os.Stat(e.Name); err == nil && info.IsDir() && filepath.Dir(e.Name) == w.dir {
+					w.watcher.Add(e.Name) //a new theme
+				}
+			}
+			if IsCustom(e.Name) || filepath.Dir(e.Name) != w.dir || filepath.Ext(e.Name) == "" {
+				timer.Reset(debounce) //an icon, a file of a theme or a theme
+			}
+		case <-w.watcher.Errors:
+		case <-timer.C:
+			select {
+			case changes <- struct{}{}:
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
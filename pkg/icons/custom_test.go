@@ -0,0 +1,84 @@
+package icons
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngOfSize(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, ValidateName("rocket.png"))
+	assert.NoError(t, ValidateName("Rocket.PNG"))
+	for _, name := range []string{"mouse", "rocket.svg", "../rocket.png", `sub\rocket.png`, ".hidden.png"} {
+		assert.Error(t, ValidateName(name), name)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := pngOfSize(t, 32, 32)
+	assert.NoError(t, Validate(data))
+	assert.ErrorContains(t, Validate(pngOfSize(t, 8, 8)), "8x8 pixels")
+	assert.ErrorContains(t, Validate([]byte("GIF89a")), "not a PNG image")
+
+	truncated := data[:len(data)-20]
+	_, err := png.DecodeConfig(bytes.NewReader(truncated))
+	require.NoError(t, err, "the header should still be readable")
+	assert.ErrorContains(t, Validate(truncated), "damaged PNG image")
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rocket.png"), pngOfSize(t, 64, 64), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "anchor.png"), pngOfSize(t, 22, 22), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tiny.png"), pngOfSize(t, 8, 8), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fake.png"), []byte("GIF89a"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an icon"), 0o644))
+
+	found, problems := Scan(dir)
+	require.Len(t, found, 2)
+	assert.Equal(t, "anchor.png", found[0].Name)
+	assert.Equal(t, "rocket.png", found[1].Name)
+	assert.NotEmpty(t, found[1].Data)
+	assert.Len(t, problems, 2, "too small and not a PNG image")
+
+	found, problems = Scan(filepath.Join(dir, "missing"))
+	assert.Empty(t, found)
+	assert.Empty(t, problems)
+
+	_, err := Load(dir, "tiny.png")
+	assert.ErrorContains(t, err, "8x8 pixels")
+}
+
+func TestWatch(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "icons")
+	watcher, err := Watch(dir, 50*time.Millisecond)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rocket.png"), pngOfSize(t, 32, 32), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "anchor.png"), pngOfSize(t, 32, 32), 0o644))
+	select {
+	case <-watcher.Changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change")
+	}
+	select {
+	case <-watcher.Changes:
+		t.Fatal("changes made together should be reported once")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,151 @@
+package icons
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// BlendMode is how a tint is combined with the colors of an icon
+type BlendMode string
+
+const (
+	// Tint paints the icon in the color, keeping the shape and its
+	// anti-aliased edges. The alpha of the color makes the icon translucent.
+	Tint BlendMode = "tint"
+	// Multiply darkens the icon by the color, keeping its details
+	Multiply BlendMode = "multiply"
+	// Overlay colors the icon keeping its highlights and shadows
+	Overlay BlendMode = "overlay"
+)
+
+// BlendModes are the modes accepted by ParseBlendMode
+var BlendModes = []BlendMode{Tint, Multiply, Overlay}
+
+// ParseBlendMode reads a blend mode, "" meaning Tint
+func ParseBlendMode(s string) (BlendMode, error) {
+	if s == "" {
+		return Tint, nil
+	}
+	for _, mode := range BlendModes {
+		if BlendMode(s) == mode {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not one of %q", s, BlendModes)
+}
+
+// Style tells how to render an icon
+type Style struct {
+	// Color tints the icon, the icon keeps its colors when nil. With
+	// Multiply and Overlay, the alpha of the color is the strength of the
+	// effect.
+	Color *color.NRGBA
+	Mode  BlendMode //Tint when empty
+	// Opacity multiplies the alpha of every pixel, from 0 to 1. 0 leaves
+	// the icon opaque, as an invisible icon is of no use.
+	Opacity float64
+	// Badge is drawn over the icon after it is tinted, in its own colors
+	Badge Badge
+}
+
+// Render returns a copy of the image in the style. Every pixel keeps its
+// alpha coverage, so anti-aliased edges stay smooth.
+func Render(src image.Image, style Style) *image.NRGBA {
+	dst := toNRGBA(src)
+	opacity := style.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	blend := blendFunc(style.Mode)
+	for i := 0; i+3 < len(dst.Pix); i += 4 {
+		p := dst.Pix[i : i+4 : i+4]
+		if p[3] == 0 {
+			continue
+		}
+		alpha := float64(p[3])
+		if c := style.Color; c != nil {
+			if style.Mode == Tint || style.Mode == "" {
+				p[0], p[1], p[2] = c.R, c.G, c.B
+				alpha = alpha * float64(c.A) / 255
+			} else {
+				strength := uint32(c.A)
+				p[0] = mix(p[0], blend(p[0], c.R), strength)
+				p[1] = mix(p[1], blend(p[1], c.G), strength)
+				p[2] = mix(p[2], blend(p[2], c.B), strength)
+			}
+		}
+		p[3] = uint8(alpha*opacity + 0.5)
+	}
+	drawBadge(dst, style.Badge)
+	return dst
+}
+
+// RenderPNG decodes a PNG image, renders it in the style and encodes it
+func RenderPNG(data []byte, style Style) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, Render(img, style)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// toNRGBA copies the image to a non-premultiplied buffer, reading the
+// pixels of RGBA and NRGBA images directly
+func toNRGBA(src image.Image) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	switch src := src.(type) {
+	case *image.NRGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			copy(dst.Pix[y*dst.Stride:y*dst.Stride+bounds.Dx()*4], src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):])
+		}
+	case *image.RGBA:
+		for y := 0; y < bounds.Dy(); y++ {
+			in := src.Pix[src.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+			out := dst.Pix[y*dst.Stride:]
+			for x := 0; x < bounds.Dx()*4; x += 4 {
+				a := uint32(in[x+3])
+				out[x+3] = in[x+3]
+				if a == 0 {
+					continue
+				}
+				out[x] = uint8((uint32(in[x])*255 + a/2) / a) //undo the premultiplication
+				out[x+1] = uint8((uint32(in[x+1])*255 + a/2) / a)
+				out[x+2] = uint8((uint32(in[x+2])*255 + a/2) / a)
+			}
+		}
+	default:
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	}
+	return dst
+}
+
+func blendFunc(mode BlendMode) func(base, tint uint8) uint8 {
+	switch mode {
+	case Multiply:
+		return func(base, tint uint8) uint8 {
+			return uint8((uint32(base)*uint32(tint) + 127) / 255)
+		}
+	case Overlay:
+		return func(base, tint uint8) uint8 {
+			if base < 128 {
+				return uint8((2*uint32(base)*uint32(tint) + 127) / 255)
+			}
+			return uint8(255 - (2*uint32(255-base)*uint32(255-tint)+127)/255)
+		}
+	}
+	return func(_, tint uint8) uint8 { return tint }
+}
+
+// mix goes from a to b, strength being from 0 to 255
+func mix(a, b uint8, strength uint32) uint8 {
+	return uint8((uint32(a)*(255-strength) + uint32(b)*strength + 127) / 255)
+}
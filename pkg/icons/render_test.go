@@ -0,0 +1,78 @@
+package icons
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// edge is an icon with an opaque gray pixel and an anti-aliased one
+func edge() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{100, 100, 100, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{100, 100, 100, 64})
+	return img
+}
+
+func TestRenderTintKeepsAntiAliasing(t *testing.T) {
+	blue := color.NRGBA{30, 144, 255, 255}
+	img := Render(edge(), Style{Color: &blue})
+	assert.Equal(t, blue, img.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{30, 144, 255, 64}, img.NRGBAAt(1, 0), "edges keep their coverage")
+	assert.Equal(t, color.NRGBA{}, img.NRGBAAt(2, 0), "transparent pixels stay transparent")
+
+	translucent := color.NRGBA{30, 144, 255, 128}
+	img = Render(edge(), Style{Color: &translucent})
+	assert.Equal(t, uint8(128), img.NRGBAAt(0, 0).A)
+	assert.Equal(t, uint8(32), img.NRGBAAt(1, 0).A)
+}
+
+func TestRenderBlendModes(t *testing.T) {
+	tint := color.NRGBA{255, 0, 128, 255}
+	img := Render(edge(), Style{Color: &tint, Mode: Multiply})
+	assert.Equal(t, color.NRGBA{100, 0, 50, 255}, img.NRGBAAt(0, 0))
+	assert.Equal(t, uint8(64), img.NRGBAAt(1, 0).A)
+
+	img = Render(edge(), Style{Color: &tint, Mode: Overlay})
+	assert.Equal(t, color.NRGBA{200, 0, 100, 255}, img.NRGBAAt(0, 0))
+
+	half := color.NRGBA{0, 0, 0, 128}
+	img = Render(edge(), Style{Color: &half, Mode: Multiply})
+	assert.Equal(t, color.NRGBA{50, 50, 50, 255}, img.NRGBAAt(0, 0), "the alpha of the color is the strength")
+}
+
+func TestRenderOpacity(t *testing.T) {
+	img := Render(edge(), Style{Opacity: 0.5})
+	assert.Equal(t, color.NRGBA{100, 100, 100, 128}, img.NRGBAAt(0, 0), "colors are kept without a tint")
+	assert.Equal(t, uint8(32), img.NRGBAAt(1, 0).A)
+	assert.Equal(t, edge().Pix, Render(edge(), Style{}).Pix, "opaque by default")
+}
+
+func TestRenderReadsPremultipliedImages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(5, 5, 7, 6))
+	src.Set(5, 5, color.NRGBA{200, 100, 50, 128})
+	img := Render(src, Style{})
+	assert.Equal(t, image.Rect(0, 0, 2, 1), img.Bounds())
+	got := img.NRGBAAt(0, 0)
+	assert.InDelta(t, 200, int(got.R), 1)
+	assert.InDelta(t, 100, int(got.G), 1)
+	assert.Equal(t, uint8(128), got.A)
+
+	gray := image.NewGray(image.Rect(0, 0, 1, 1))
+	gray.Pix[0] = 77
+	assert.Equal(t, color.NRGBA{77, 77, 77, 255}, Render(gray, Style{}).NRGBAAt(0, 0))
+}
+
+func TestParseBlendMode(t *testing.T) {
+	mode, err := ParseBlendMode("")
+	require.NoError(t, err)
+	assert.Equal(t, Tint, mode)
+	mode, err = ParseBlendMode("overlay")
+	require.NoError(t, err)
+	assert.Equal(t, Overlay, mode)
+	_, err = ParseBlendMode("screen")
+	assert.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package icons
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/draw"
+)
+
+// Scales are the resolutions of the variants of a PNG icon: mouse.png,
+// mouse@2x.png and mouse@3x.png
+var Scales = []int{1, 2, 3}
+
+// Source is the file an icon is read from
+type Source struct {
+	Path  string
+	Data  []byte
+	SVG   bool
+	Scale int //resolution of a PNG file, 1 for mouse.png
+}
+
+// FindSource returns the best file of the icon for the display scale, from
+// the first directory that has one. An SVG file wins as it is sharp at any
+// size. Otherwise the PNG variant of the scale is used, then those of higher
+// scales, scaled down, then those of lower ones.
+func FindSource(dirs []string, name string, scale int) (Source, error) {
+	for _, dir := range dirs {
+		for _, candidate := range candidates(name, scale) {
+			path := filepath.Join(dir, candidate.Path)
+			data, err := os.ReadFile(path)
+			if err != nil || len(data) == 0 {
+				continue
+			}
+			candidate.Path, candidate.Data = path, data
+			return candidate, nil
+		}
+	}
+	return Source{}, fmt.Errorf("no file for icon %v in %q", name, dirs)
+}
+
+// candidates are the file names of an icon by preference
+func candidates(name string, scale int) []Source {
+	list := []Source{{Path: name + ".svg", SVG: true}}
+	add := func(s int) {
+		file := name + ".png"
+		if s > 1 {
+			file = fmt.Sprintf("%v@%dx.png", name, s)
+		}
+		list = append(list, Source{Path: file, Scale: s})
+	}
+	for _, s := range Scales {
+		if s >= scale {
+			add(s)
+		}
+	}
+	for i := len(Scales) - 1; i >= 0; i-- {
+		if Scales[i] < scale {
+			add(Scales[i])
+		}
+	}
+	return list
+}
+
+// Image returns the icon at most size pixels high. SVG files are drawn at
+// that size; PNG files larger than it are scaled down, smaller ones are
+// left as they are rather than blurred.
+func (s Source) Image(size int) (*image.NRGBA, error) {
+	if s.SVG {
+		return rasterize(s.Data, size)
+	}
+	img, err := png.Decode(bytes.NewReader(s.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", s.Path, err)
+	}
+	return fit(img, size), nil
+}
+
+// PNG returns the icon at most size pixels high encoded as PNG, the file
+// itself if it needs no change
+func (s Source) PNG(size int) ([]byte, error) {
+	if !s.SVG {
+		config, err := png.DecodeConfig(bytes.NewReader(s.Data))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", s.Path, err)
+		}
+		if config.Height <= size {
+			return s.Data, nil
+		}
+	}
+	img, err := s.Image(size)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rasterize draws an SVG icon in a square of size pixels
+func rasterize(data []byte, size int) (*image.NRGBA, error) {
+	if size <= 0 {
+		return nil, errors.New("invalid icon size")
+	}
+	icon, err := oksvg.ReadIconStream(bytes.NewReader(data), oksvg.WarnErrorMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SVG: %w", err)
+	}
+	icon.SetTarget(0, 0, float64(size), float64(size))
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	scanner := rasterx.NewScannerGV(size, size, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(size, size, scanner), 1)
+	return toNRGBA(img), nil
+}
+
+// fit scales the image down to size pixels high, keeping its proportions
+func fit(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	if bounds.Dy() <= size {
+		return toNRGBA(img)
+	}
+	width := bounds.Dx() * size / bounds.Dy()
+	if width < 1 {
+		width = 1
+	}
+	//scaled with premultiplied alpha, so that transparent pixels do not
+	//darken the edges
+	scaled := image.NewRGBA(image.Rect(0, 0, width, size))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+	return toNRGBA(scaled)
+}
@@ -0,0 +1,85 @@
+package icons
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const circleSVG = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><circle cx="8" cy="8" r="6" fill="#000"/></svg>`
+
+func TestFindSource(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	write := func(dir, name string, data []byte) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+	write(first, "mouse.png", pngOfSize(t, 16, 16))
+	write(first, "mouse@3x.png", pngOfSize(t, 48, 48))
+	write(second, "mouse@2x.png", pngOfSize(t, 32, 32))
+	write(second, "cloud.svg", []byte(circleSVG))
+	write(second, "cloud.png", pngOfSize(t, 16, 16))
+	dirs := []string{first, second}
+
+	for _, test := range []struct {
+		name  string
+		scale int
+		want  string
+	}{
+		{"mouse", 1, "mouse.png"},
+		{"mouse", 2, "mouse@3x.png"}, //scaled down rather than up
+		{"mouse", 3, "mouse@3x.png"},
+		{"cloud", 2, "cloud.svg"},
+	} {
+		source, err := FindSource(dirs, test.name, test.scale)
+		require.NoError(t, err)
+		assert.Equal(t, test.want, filepath.Base(source.Path), "%v at %dx", test.name, test.scale)
+	}
+	_, err := FindSource(dirs, "man", 1)
+	assert.Error(t, err)
+}
+
+func TestSourceImage(t *testing.T) {
+	svg := Source{Path: "cloud.svg", Data: []byte(circleSVG), SVG: true}
+	img, err := svg.Image(44)
+	require.NoError(t, err)
+	assert.Equal(t, 44, img.Bounds().Dx())
+	assert.Equal(t, uint8(255), img.NRGBAAt(22, 22).A, "inside the circle")
+	assert.Equal(t, uint8(0), img.NRGBAAt(1, 1).A, "outside of the circle")
+	data, err := svg.PNG(44)
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 44, decoded.Bounds().Dy())
+
+	large := Source{Path: "mouse@3x.png", Data: pngOfSize(t, 96, 48), Scale: 3}
+	img, err = large.Image(22)
+	require.NoError(t, err)
+	assert.Equal(t, 44, img.Bounds().Dx(), "proportions are kept")
+	assert.Equal(t, 22, img.Bounds().Dy())
+
+	small := Source{Path: "mouse.png", Data: pngOfSize(t, 16, 16), Scale: 1}
+	data, err = small.PNG(44)
+	require.NoError(t, err)
+	assert.Equal(t, small.Data, data, "small files are used as they are")
+
+	_, err = Source{Path: "bad.svg", Data: []byte("<svg"), SVG: true}.Image(16)
+	assert.Error(t, err)
+}
+
+func TestFitKeepsEdgesClean(t *testing.T) {
+	img := solid(64, color.NRGBA{255, 0, 0, 255})
+	for y := 0; y < 64; y++ {
+		for x := 32; x < 64; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{}) //transparent black
+		}
+	}
+	scaled := fit(img, 16)
+	edge := scaled.NRGBAAt(7, 8)
+	assert.Greater(t, edge.R, uint8(240), "transparent pixels should not darken the edge, got %v", edge)
+}
@@ -0,0 +1,264 @@
+package icons
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ThemePrefix marks the themes in the icon setting, e.g. theme:neon for the
+// theme of the neon directory
+const ThemePrefix = "theme:"
+
+// ManifestFile describes the theme of a directory
+const ManifestFile = "theme.json"
+
+// States of the mouse mover having an icon in themes. Only the active icon
+// is required, the others default to it.
+const (
+	ActiveState   = "active"
+	InactiveState = "inactive"
+	PausedState   = "paused"
+	ErrorState    = "error"
+)
+
+// ThemeStates are the states a theme can have an icon for
+var ThemeStates = []string{ActiveState, InactiveState, PausedState, ErrorState}
+
+// Manifest is the content of a theme.json file:
+//
+//	{
+//	  "name": "Neon",
+//	  "author": "Jane Doe",
+//	  "tint": "#ff00ffff",
+//	  "icons": {"active": "on", "paused": "zzz"}
+//	}
+//
+// Icons are named without extension, on.svg, on.png and on@2x.png all
+// being candidates for "on".
+type Manifest struct {
+	Name   string            `json:"name"`
+	Author string            `json:"author,omitempty"`
+	Tint   string            `json:"tint,omitempty"` //used when the profile has no color
+	Icons  map[string]string `json:"icons"`
+}
+
+// Theme is a valid theme directory
+type Theme struct {
+	ID  string //directory name
+	Dir string
+	Manifest
+}
+
+// IsTheme tells if an icon name refers to a theme
+func IsTheme(name string) bool {
+	return strings.HasPrefix(name, ThemePrefix)
+}
+
+// ThemeName returns the icon name of the theme
+func ThemeName(id string) string {
+	return ThemePrefix + id
+}
+
+// ValidateThemeName checks that the icon name refers to a theme directory
+func ValidateThemeName(name string) error {
+	id := strings.TrimPrefix(name, ThemePrefix)
+	if !IsTheme(name) || id == "" || id != filepath.Base(id) || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("%q is not the name of a theme directory, e.g. %vneon", name, ThemePrefix)
+	}
+	return nil
+}
+
+// Icon returns the name of the icon of the state
+func (t Theme) Icon(state string) string {
+	if icon, ok := t.Icons[state]; ok {
+		return icon
+	}
+	return t.Icons[ActiveState]
+}
+
+// Source returns the file of the icon of the state for the display scale
+func (t Theme) Source(state string, scale int) (Source, error) {
+	return FindSource([]string{t.Dir}, t.Icon(state), scale)
+}
+
+// LoadTheme reads and checks the manifest of the theme directory, and the
+// icons it refers to
+func LoadTheme(dir string) (Theme, error) {
+	theme := Theme{ID: filepath.Base(dir), Dir: dir}
+	path := filepath.Join(dir, ManifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return theme, err
+	}
+	err = theme.load(data, func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, name))
+	})
+	if err != nil {
+		return theme, fmt.Errorf("%v: %w", path, err)
+	}
+	return theme, nil
+}
+
+// ParseTheme checks a theme given the content of its files by name, e.g.
+// theme.json and on.svg, such as the files of an archive. The theme has no
+// directory.
+func ParseTheme(id string, files map[string][]byte) (Theme, error) {
+	theme := Theme{ID: id}
+	data, ok := files[ManifestFile]
+	if !ok {
+		return theme, fmt.Errorf("no %v", ManifestFile)
+	}
+	err := theme.load(data, func(name string) ([]byte, error) {
+		if data, ok := files[name]; ok {
+			return data, nil
+		}
+		return nil, os.ErrNotExist
+	})
+	if err != nil {
+		return theme, fmt.Errorf("%v: %w", ManifestFile, err)
+	}
+	return theme, nil
+}
+
+// load decodes the manifest and checks it, read giving the content of the
+// files of the theme by name
+func (t *Theme) load(manifest []byte, read func(name string) ([]byte, error)) error {
+	decoder := json.NewDecoder(bytes.NewReader(manifest))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&t.Manifest); err != nil {
+		return err
+	}
+	return t.validate(read)
+}
+
+// validate reports every problem of the manifest at once
+func (t Theme) validate(read func(name string) ([]byte, error)) error {
+	var problems []error
+	if strings.TrimSpace(t.Name) == "" {
+		problems = append(problems, errors.New("name is missing"))
+	}
+	if t.Tint != "" {
+		if _, err := ParseColor(t.Tint); err != nil {
+			problems = append(problems, fmt.Errorf("tint: %w", err))
+		}
+	}
+	if _, ok := t.Icons[ActiveState]; !ok {
+		problems = append(problems, errors.New("icons: the active icon is missing"))
+	}
+	states := make([]string, 0, len(t.Icons))
+	for state := range t.Icons {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		if !isThemeState(state) {
+			problems = append(problems, fmt.Errorf("icons: unknown state %q, expected %v", state, strings.Join(ThemeStates, ", ")))
+			continue
+		}
+		if err := t.validateIcon(state, read); err != nil {
+			problems = append(problems, fmt.Errorf("icons: %v: %w", state, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// validateIcon checks the files of the icon of the state
+func (t Theme) validateIcon(state string, read func(name string) ([]byte, error)) error {
+	name := t.Icons[state]
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || filepath.Ext(name) != "" {
+		return fmt.Errorf("%q is not an icon name, e.g. on for on.svg or on.png", name)
+	}
+	found := false
+	for _, candidate := range candidates(name, 1) {
+		data, err := read(candidate.Path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		if len(data) > MaxFileSize {
+			return fmt.Errorf("%v: file larger than %d KB", candidate.Path, MaxFileSize>>10)
+		}
+		if candidate.SVG {
+			if _, err := rasterize(data, MinSize); err != nil {
+				return fmt.Errorf("%v: %w", candidate.Path, err)
+			}
+		} else if err := Validate(data); err != nil {
+			return fmt.Errorf("%v: %w", candidate.Path, err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no file %v.svg or %v.png", name, name)
+	}
+	return nil
+}
+
+func isThemeState(state string) bool {
+	for _, s := range ThemeStates {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTheme loads the theme of the icon name from the first directory
+// having it
+func FindTheme(dirs []string, name string) (Theme, error) {
+	if err := ValidateThemeName(name); err != nil {
+		return Theme{}, err
+	}
+	id := strings.TrimPrefix(name, ThemePrefix)
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, id, ManifestFile)); err == nil {
+			return LoadTheme(filepath.Join(dir, id))
+		}
+	}
+	return Theme{}, fmt.Errorf("no theme %v in %q", id, dirs)
+}
+
+// ScanThemes returns the valid themes of the directories sorted by ID, and
+// an error for each invalid one. A theme of the first directories hides the
+// ones of the same ID in the next.
+func ScanThemes(dirs []string) ([]Theme, []error) {
+	var found []Theme
+	var problems []error
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || seen[entry.Name()] || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(dir, entry.Name(), ManifestFile)); err != nil {
+				continue //not a theme
+			}
+			seen[entry.Name()] = true
+			theme, err := LoadTheme(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				problems = append(problems, err)
+				continue
+			}
+			found = append(found, theme)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].ID < found[j].ID
+	})
+	return found, problems
+}
@@ -0,0 +1,154 @@
+package icons
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTheme creates a theme directory with the manifest and files
+func writeTheme(t *testing.T, dir, manifest string, files map[string][]byte) string {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ManifestFile), []byte(manifest), 0o644))
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+	return dir
+}
+
+func TestLoadTheme(t *testing.T) {
+	dir := writeTheme(t, filepath.Join(t.TempDir(), "neon"), `{
+		"name": "Neon",
+		"author": "Jane Doe",
+		"tint": "#ff00ff",
+		"icons": {"active": "on", "paused": "zzz"}
+	}`, map[string][]byte{
+		"on.png":    pngOfSize(t, 32, 32),
+		"on@2x.png": pngOfSize(t, 64, 64),
+		"zzz.svg":   []byte(circleSVG),
+	})
+
+	theme, err := LoadTheme(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "neon", theme.ID)
+	assert.Equal(t, "Neon", theme.Name)
+	assert.Equal(t, "Jane Doe", theme.Author)
+	assert.Equal(t, "zzz", theme.Icon(PausedState))
+	assert.Equal(t, "on", theme.Icon(ErrorState), "missing states use the active icon")
+
+	source, err := theme.Source(ActiveState, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "on@2x.png", filepath.Base(source.Path))
+	source, err = theme.Source(PausedState, 1)
+	require.NoError(t, err)
+	assert.True(t, source.SVG)
+}
+
+func TestLoadThemeErrors(t *testing.T) {
+	base := t.TempDir()
+	for _, test := range []struct {
+		name     string
+		manifest string
+		files    map[string][]byte
+		errors   []string
+	}{
+		{"syntax", `{"name": `, nil, []string{"theme.json"}},
+		{"unknown field", `{"name": "A", "icon": {}}`, nil, []string{`unknown field "icon"`}},
+		{"empty", `{}`, nil, []string{"name is missing", "the active icon is missing"}},
+		{"fields", `{"name": "A", "tint": "pink", "icons": {"active": "on", "sleeping": "on", "error": "../on"}}`,
+			map[string][]byte{"on.png": pngOfSize(t, 32, 32)},
+			[]string{`tint: "pink" is not`, `unknown state "sleeping"`, `error: "../on" is not an icon name`}},
+		{"files", `{"name": "A", "icons": {"active": "on", "paused": "tiny", "error": "bad"}}`,
+			map[string][]byte{"tiny.png": pngOfSize(t, 8, 8), "bad.svg": []byte("<svg")},
+			[]string{"active: no file on.svg or on.png", "paused: tiny.png: 8x8 pixels", "error: bad.svg: invalid SVG"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeTheme(t, filepath.Join(base, test.name), test.manifest, test.files)
+			_, err := LoadTheme(dir)
+			require.Error(t, err)
+			for _, message := range test.errors {
+				assert.Contains(t, err.Error(), message)
+			}
+		})
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	files := map[string][]byte{
+		ManifestFile: []byte(`{"name": "Neon", "icons": {"active": "on", "paused": "zzz"}}`),
+		"on.png":     pngOfSize(t, 32, 32),
+		"zzz.svg":    []byte(circleSVG),
+	}
+	theme, err := ParseTheme("neon", files)
+	require.NoError(t, err)
+	assert.Equal(t, "neon", theme.ID)
+	assert.Equal(t, "zzz", theme.Icon(PausedState))
+
+	delete(files, "zzz.svg")
+	_, err = ParseTheme("neon", files)
+	assert.ErrorContains(t, err, "paused: no file zzz.svg or zzz.png")
+	delete(files, ManifestFile)
+	_, err = ParseTheme("neon", files)
+	assert.ErrorContains(t, err, "no theme.json")
+}
+
+func TestFindAndScanThemes(t *testing.T) {
+	user, bundled := t.TempDir(), t.TempDir()
+	files := map[string][]byte{"on.png": pngOfSize(t, 32, 32)}
+	writeTheme(t, filepath.Join(user, "neon"), `{"name": "My neon", "icons": {"active": "on"}}`, files)
+	writeTheme(t, filepath.Join(bundled, "neon"), `{"name": "Neon", "icons": {"active": "on"}}`, files)
+	writeTheme(t, filepath.Join(bundled, "classic"), `{"name": "Classic", "icons": {"active": "on"}}`, files)
+	writeTheme(t, filepath.Join(bundled, "broken"), `{"name": "Broken"}`, nil)
+	require.NoError(t, os.MkdirAll(filepath.Join(user, "misc"), 0o755)) //not a theme
+	dirs := []string{user, filepath.Join(t.TempDir(), "missing"), bundled}
+
+	themes, problems := ScanThemes(dirs)
+	require.Len(t, themes, 2)
+	assert.Equal(t, "classic", themes[0].ID)
+	assert.Equal(t, "My neon", themes[1].Name, "the first directories win")
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Error(), "broken")
+
+	theme, err := FindTheme(dirs, "theme:neon")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(user, "neon"), theme.Dir)
+	_, err = FindTheme(dirs, "theme:broken")
+	assert.ErrorContains(t, err, "the active icon is missing")
+	_, err = FindTheme(dirs, "theme:other")
+	assert.Error(t, err)
+	_, err = FindTheme(dirs, "theme:../neon")
+	assert.Error(t, err)
+}
+
+func TestValidateThemeName(t *testing.T) {
+	assert.NoError(t, ValidateThemeName("theme:neon"))
+	for _, name := range []string{"neon", "theme:", "theme:a/b", "theme:..", "theme:.hidden"} {
+		assert.Error(t, ValidateThemeName(name), name)
+	}
+}
+
+func TestWatchThemes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "icons")
+	watcher, err := Watch(dir, 50*time.Millisecond)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	theme := filepath.Join(dir, "neon")
+	require.NoError(t, os.Mkdir(theme, 0o755))
+	select {
+	case <-watcher.Changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change for the new theme")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(theme, ManifestFile), []byte(`{}`), 0o644))
+	select {
+	case <-watcher.Changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change for the manifest")
+	}
+}
@@ -0,0 +1,53 @@
+package mousemover
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits restrict when the mouse may be moved, e.g. by a system policy
+type Limits struct {
+	// Allowed tells if the mouse may be moved at the given time, always
+	// when nil
+	Allowed func(time.Time) bool
+	// MaxKeepAwake stops moving the mouse once nobody used the machine for
+	// that long, no limit when 0
+	MaxKeepAwake time.Duration
+}
+
+// blocks returns why the mouse cannot be moved now, empty if it can
+func (l Limits) blocks(now, lastActivity time.Time) string {
+	if l.Allowed != nil && !l.Allowed(now) {
+		return "outside of the allowed hours"
+	}
+	if l.MaxKeepAwake > 0 && now.Sub(lastActivity) >= l.MaxKeepAwake {
+		return fmt.Sprintf("no activity for more than %v", l.MaxKeepAwake)
+	}
+	return ""
+}
+
+// Strategies are the ways of moving the mouse
+const (
+	Jiggle = "jiggle" //10 pixels away and back, the default
+	Nudge  = "nudge"  //a single pixel away and back, hardly visible
+)
+
+// SetStrategy changes how the mouse moves, "" meaning Jiggle. It is used the
+// next time the app is started.
+func (m *MouseMover) SetStrategy(strategy string) {
+	m.strategy = strategy
+}
+
+// movePixels is how far the mouse moves for the strategy
+func (m *MouseMover) movePixels() int {
+	if m.strategy == Nudge {
+		return 1
+	}
+	return 10
+}
+
+// SetLimits restricts when the mouse may be moved. They are used the next
+// time the app is started.
+func (m *MouseMover) SetLimits(limits Limits) {
+	m.limits = limits
+}
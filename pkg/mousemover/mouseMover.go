@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/i18n"
 	"github.com/go-vgo/robotgo"
 	"github.com/resousse/activity-tracker/pkg/activity"
 	"github.com/resousse/activity-tracker/pkg/tracker"
@@ -12,9 +14,11 @@ import (
 var instance *MouseMover
 
 const (
-	timeout     = 100 //ms
-	logDir      = "log"
-	logFileName = "logFile-amm-5"
+	timeout = 100 //ms
+	//default seconds without activity before moving
+	defaultHeartbeatInterval = 60
+	logDir                   = "log"
+	logFileName              = "logFile-amm-5"
 )
 
 // Start the main app
@@ -25,7 +29,7 @@ func (m *MouseMover) Start() {
 	m.state = &state{}
 	m.quit = make(chan struct{})
 
-	heartbeatInterval := 60 //value always in seconds
+	heartbeatInterval := m.interval() //value always in seconds
 	workerInterval := 10
 
 	activityTracker := &tracker.Instance{
@@ -39,23 +43,48 @@ func (m *MouseMover) Start() {
 }
 
 func (m *MouseMover) run(heartbeatCh chan *tracker.Heartbeat, activityTracker *tracker.Instance) {
+	//owned by this run, Start replaces them for the next one
+	state, quit := m.state, m.quit
+	if state != nil && state.isRunning() {
+		return
+	}
+	//right away, for a Quit that follows to stop it
+	state.updateRunningStatus(true)
+	m.publish(state, event.Started, "")
+	stopped := make(chan struct{})
+	m.stopped = stopped
 	go func() {
-		state := m.state
-		if state != nil && state.isRunning() {
-			return
-		}
-		state.updateRunningStatus(true)
+		defer close(stopped)
 
 		logger := getLogger(m, false, logFileName) //set writeToFile=true only for debugging
-		movePixel := 10
+		movePixel := m.movePixels()
+		var presence event.Type
+		limits := m.limits
+		state.updateLastActivityTime(time.Now())
 		for {
 			select {
 			case heartbeat := <-heartbeatCh:
 				if !heartbeat.WasAnyActivity {
+					if presence != event.Idle {
+						presence = event.Idle
+						m.publish(state, event.Idle, "")
+					}
 					if state.isSystemSleeping() {
 						logger.Infof("system sleeping")
 						continue
 					}
+					if pausedUntil := state.getPausedUntil(); !pausedUntil.IsZero() {
+						if time.Now().Before(pausedUntil) {
+							logger.Infof("paused until %v", pausedUntil)
+							continue
+						}
+						state.updatePausedUntil(time.Time{})
+						m.publish(state, event.Resumed, "")
+					}
+					if reason := limits.blocks(time.Now(), state.getLastActivityTime()); reason != "" {
+						logger.Infof("not moving the mouse: %v", reason)
+						continue
+					}
 					mouseMoveSuccessCh := make(chan bool)
 					go moveAndCheck(state, movePixel, mouseMoveSuccessCh)
 					select {
@@ -65,6 +94,7 @@ func (m *MouseMover) run(heartbeatCh chan *tracker.Heartbeat, activityTracker *t
 							logger.Infof("Is system sleeping? : %v : moved mouse at : %v\n\n", state.isSystemSleeping(), state.getLastMouseMovedTime())
 							movePixel *= -1
 							state.updateDidNotMoveCount(0)
+							m.publish(state, event.Moved, "")
 						} else {
 							didNotMoveCount := state.getDidNotMoveCount()
 							state.updateDidNotMoveCount(didNotMoveCount + 1)
@@ -72,9 +102,11 @@ func (m *MouseMover) run(heartbeatCh chan *tracker.Heartbeat, activityTracker *t
 							msg := fmt.Sprintf("Mouse pointer cannot be moved at %v. Last moved at %v. Happened %v times. (Only notifies once every 24 hours.) See README for details.",
 								time.Now(), state.getLastMouseMovedTime(), state.getDidNotMoveCount())
 							logger.Error(msg)
+							m.publish(state, event.MoveFailed, msg)
 							if state.getDidNotMoveCount() >= 10 && (time.Since(state.lastErrorTime).Hours() > 24) { //show only 1 error in a 24 hour window
+								alert := i18n.T("alert.moveFailed", time.Now(), state.getLastMouseMovedTime(), state.getDidNotMoveCount())
 								go func() {
-									robotgo.Alert("Error with Automatic Mouse Mover", msg)
+									robotgo.Alert(i18n.T("alert.moveFailed.title"), alert)
 								}()
 							}
 						}
@@ -83,6 +115,7 @@ func (m *MouseMover) run(heartbeatCh chan *tracker.Heartbeat, activityTracker *t
 						logger.Errorf("timeout happened after %vms while trying to move mouse", timeout)
 					}
 				} else {
+					wasSleeping := state.isSystemSleeping()
 					logger.Infof("activity detected in the last %v seconds.", int(activityTracker.HeartbeatInterval))
 					logger.Infof("Activity type:\n")
 					for activityType, times := range heartbeat.ActivityMap {
@@ -96,23 +129,42 @@ func (m *MouseMover) run(heartbeatCh chan *tracker.Heartbeat, activityTracker *t
 						}
 					}
 					logger.Infof("\n\n\n")
+					if isSleeping := state.isSystemSleeping(); isSleeping != wasSleeping {
+						if isSleeping {
+							m.publish(state, event.Sleep, "")
+						} else {
+							m.publish(state, event.Wake, "")
+						}
+					}
+					if !state.isSystemSleeping() {
+						state.updateLastActivityTime(time.Now())
+					}
+					if presence != event.Active && !state.isSystemSleeping() {
+						presence = event.Active
+						m.publish(state, event.Active, "")
+					}
 				}
-			case <-m.quit:
+			case <-quit:
 				logger.Infof("stopping mouse mover")
 				state.updateRunningStatus(false)
 				activityTracker.Quit()
+				m.publish(state, event.Stopped, "")
 				return
 			}
 		}
 	}()
 }
 
-// Quit the app
+// Quit the app. It returns once the stop event is published, so that the
+// app can be started again right away.
 func (m *MouseMover) Quit() {
 	//making it idempotent
 	if m != nil && m.state.isRunning() {
 		m.state.updateRunningStatus(false)
 		m.quit <- struct{}{}
+		if m.stopped != nil {
+			<-m.stopped
+		}
 	}
 	if m.logFile != nil {
 		m.logFile.Close()
@@ -123,8 +175,92 @@ func (m *MouseMover) Quit() {
 func GetInstance() *MouseMover {
 	if instance == nil {
 		instance = &MouseMover{
-			state: &state{},
+			state:  &state{},
+			events: &event.Bus{},
 		}
 	}
 	return instance
 }
+
+// Pause keeps the mouse still for the given duration without stopping the
+// app. It does nothing while the app is stopped.
+func (m *MouseMover) Pause(duration time.Duration) {
+	if !m.state.isRunning() {
+		return
+	}
+	pausedUntil := time.Now().Add(duration)
+	m.state.updatePausedUntil(pausedUntil)
+	m.publishEvent(m.state, event.Event{
+		Type:        event.Paused,
+		PausedUntil: pausedUntil,
+	})
+}
+
+// Resume cancels a pause started with Pause
+func (m *MouseMover) Resume() {
+	if m.state.getPausedUntil().IsZero() {
+		return
+	}
+	m.state.updatePausedUntil(time.Time{})
+	m.publish(m.state, event.Resumed, "")
+}
+
+// Status tells what the mouse mover is doing
+func (m *MouseMover) Status() Status {
+	status := Status{
+		Interval: time.Duration(m.interval()) * time.Second,
+		Limited:  m.limits.Allowed != nil || m.limits.MaxKeepAwake > 0,
+	}
+	if m.state == nil {
+		return status
+	}
+	status.Running = m.state.isRunning()
+	status.Sleeping = m.state.isSystemSleeping()
+	status.PausedUntil = m.state.getPausedUntil()
+	status.LastMoved = m.state.getLastMouseMovedTime()
+	status.DidNotMoveCount = m.state.getDidNotMoveCount()
+	status.LastActivity = m.state.getLastActivityTime()
+	return status
+}
+
+// NextMove estimates when the mouse moves next if nobody uses the machine,
+// zero when it is stopped, paused or the system sleeps. The activity is
+// checked once every interval: the mouse moves at the first check finding
+// none since the previous one.
+func (s Status) NextMove(now time.Time) time.Time {
+	if !s.Running || s.Sleeping || now.Before(s.PausedUntil) || s.Interval <= 0 {
+		return time.Time{}
+	}
+	last := s.LastActivity //both are times of a check
+	if s.LastMoved.After(last) {
+		last = s.LastMoved
+	}
+	if last.IsZero() {
+		return time.Time{}
+	}
+	next := last.Add(s.Interval)
+	if now.After(next) { //there was activity since the last check
+		next = next.Add((now.Sub(next)/s.Interval + 1) * s.Interval)
+	}
+	return next
+}
+
+// interval is the number of seconds without activity before moving
+func (m *MouseMover) interval() int {
+	if m.heartbeatInterval > 0 {
+		return m.heartbeatInterval
+	}
+	return defaultHeartbeatInterval
+}
+
+// SetHeartbeatInterval changes the number of seconds without activity before
+// the mouse moves. It is used the next time the app is started.
+func (m *MouseMover) SetHeartbeatInterval(seconds int) {
+	m.heartbeatInterval = seconds
+}
+
+// Subscribe returns a channel receiving the events of the mouse mover, and a
+// function to stop receiving them
+func (m *MouseMover) Subscribe() (<-chan event.Event, func()) {
+	return m.events.Subscribe()
+}
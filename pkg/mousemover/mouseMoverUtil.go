@@ -4,6 +4,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
 	"github.com/go-vgo/robotgo"
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
@@ -57,6 +58,26 @@ func moveAndCheck(state *state, movePixel int, mouseMoveSuccessCh chan bool) {
 	}
 }
 
+// publish notifies the subscribers, attaching the movement state of the run
+// publishing it
+func (m *MouseMover) publish(state *state, eventType event.Type, message string) {
+	m.publishEvent(state, event.Event{
+		Type:    eventType,
+		Message: message,
+	})
+}
+
+func (m *MouseMover) publishEvent(state *state, e event.Event) {
+	if state != nil {
+		e.LastMoved = state.getLastMouseMovedTime()
+		e.DidNotMoveCount = state.getDidNotMoveCount()
+		e.Running = state.isRunning()
+		e.Paused = !state.getPausedUntil().IsZero()
+		e.Sleeping = state.isSystemSleeping()
+	}
+	m.events.Publish(e)
+}
+
 // getters and setters for state variable
 func (s *state) isRunning() bool {
 	s.mutex.RLock()
@@ -117,3 +138,27 @@ func (s *state) updateDidNotMoveCount(count int) {
 	defer s.mutex.Unlock()
 	s.didNotMoveCount = count
 }
+
+func (s *state) getPausedUntil() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.pausedUntil
+}
+
+func (s *state) updatePausedUntil(time time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pausedUntil = time
+}
+
+func (s *state) getLastActivityTime() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.lastActivityTime
+}
+
+func (s *state) updateLastActivityTime(time time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastActivityTime = time
+}
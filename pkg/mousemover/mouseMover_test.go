@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 
@@ -216,3 +217,239 @@ func (suite *TestMover) TestRunPreventsMultipleStarts() {
 	mouseMover.run(suite.heartbeatCh, suite.activityTracker)
 	assert.True(t, mouseMover.state.isRunning(), "state should remain running after calling run again")
 }
+
+func (suite *TestMover) TestEventsPublished() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	events, unsubscribe := mouseMover.Subscribe()
+	defer unsubscribe()
+
+	mouseMover.state = &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	heartbeatCh := make(chan *tracker.Heartbeat)
+
+	mouseMover.run(heartbeatCh, suite.activityTracker)
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: false,
+	}
+
+	var received []event.Type
+	timeout := time.After(time.Second)
+	for len(received) < 3 {
+		select {
+		case e := <-events:
+			received = append(received, e.Type)
+		case <-timeout:
+			t.Fatalf("expected 3 events, got %v", received)
+		}
+	}
+	assert.Equal(t, []event.Type{event.Started, event.Idle, event.Moved}, received)
+}
+
+func (suite *TestMover) TestQuitWaitsForTheStopEvent() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	events, unsubscribe := mouseMover.Subscribe()
+	defer unsubscribe()
+
+	mouseMover.state = &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	mouseMover.quit = make(chan struct{})
+	activityTracker := &tracker.Instance{HeartbeatInterval: 60, WorkerInterval: 10}
+	mouseMover.run(activityTracker.StartWithHandlers(), activityTracker)
+	mouseMover.Quit()
+
+	var received []event.Event
+	for len(received) < 2 {
+		select {
+		case e := <-events:
+			received = append(received, e)
+		default:
+			t.Fatalf("expected the start and stop events once quit, got %v", received)
+		}
+	}
+	assert.Equal(t, event.Started, received[0].Type)
+	assert.True(t, received[0].Running)
+	assert.Equal(t, event.Stopped, received[1].Type)
+	assert.False(t, received[1].Running)
+}
+
+func (suite *TestMover) TestPause() {
+	t := suite.T()
+	mouseMover := GetInstance()
+
+	state := &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	mouseMover.state = state
+	heartbeatCh := make(chan *tracker.Heartbeat)
+
+	mouseMover.run(heartbeatCh, suite.activityTracker)
+	time.Sleep(time.Millisecond * 500) //wait for app to start
+	mouseMover.Pause(time.Hour)
+	assert.False(t, state.getPausedUntil().IsZero(), "should be paused")
+
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: false,
+	}
+	time.Sleep(time.Millisecond * 500) //wait for it to be registered
+	assert.True(t, state.getLastMouseMovedTime().IsZero(), "mouse should not move while paused")
+
+	mouseMover.Resume()
+	assert.True(t, state.getPausedUntil().IsZero(), "should not be paused anymore")
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: false,
+	}
+	time.Sleep(time.Millisecond * 500) //wait for it to be registered
+	assert.False(t, state.getLastMouseMovedTime().IsZero(), "mouse should move after resume")
+}
+
+func (suite *TestMover) TestPauseWhileStopped() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	events, unsubscribe := mouseMover.Subscribe()
+	defer unsubscribe()
+
+	mouseMover.Pause(time.Hour)
+	assert.True(t, mouseMover.Status().PausedUntil.IsZero(), "a stopped app should not be paused")
+	select {
+	case e := <-events:
+		t.Fatalf("expected no event, got %v", e.Type)
+	default:
+	}
+}
+
+func (suite *TestMover) TestEventsCarryTheState() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	events, unsubscribe := mouseMover.Subscribe()
+	defer unsubscribe()
+
+	mouseMover.state = &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	heartbeatCh := make(chan *tracker.Heartbeat)
+	mouseMover.run(heartbeatCh, suite.activityTracker)
+	time.Sleep(time.Millisecond * 500) //wait for app to start
+	mouseMover.Pause(time.Hour)
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: true,
+	}
+
+	received := map[event.Type]event.Event{}
+	timeout := time.After(time.Second)
+	for len(received) < 3 {
+		select {
+		case e := <-events:
+			received[e.Type] = e
+		case <-timeout:
+			t.Fatalf("expected the start, pause and active events, got %v", received)
+		}
+	}
+	assert.True(t, received[event.Started].Running)
+	assert.False(t, received[event.Started].Paused)
+	assert.True(t, received[event.Paused].Paused)
+	assert.True(t, received[event.Active].Paused, "activity does not end the pause")
+	assert.True(t, received[event.Active].Running)
+}
+
+func (suite *TestMover) TestEventsCarryTheStateOfTheirRun() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	events, unsubscribe := mouseMover.Subscribe()
+	defer unsubscribe()
+
+	stopping := &state{} //Quit marked it stopped
+	mouseMover.state = &state{isAppRunning: true}
+	mouseMover.publish(stopping, event.Stopped, "")
+	select {
+	case e := <-events:
+		assert.False(t, e.Running, "a run stopping while the next one starts should not report the next one")
+	case <-time.After(time.Second):
+		t.Fatal("expected the stop event")
+	}
+}
+
+func (suite *TestMover) TestLimits() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	allowed := false
+	mouseMover.SetLimits(Limits{Allowed: func(time.Time) bool { return allowed }})
+
+	state := &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	mouseMover.state = state
+	heartbeatCh := make(chan *tracker.Heartbeat)
+
+	mouseMover.run(heartbeatCh, suite.activityTracker)
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: false,
+	}
+	time.Sleep(time.Millisecond * 500) //wait for it to be registered
+	assert.True(t, state.getLastMouseMovedTime().IsZero(), "mouse should not move outside of the allowed hours")
+
+	now := time.Now()
+	assert.Empty(t, Limits{MaxKeepAwake: time.Hour}.blocks(now, now.Add(-59*time.Minute)))
+	assert.NotEmpty(t, Limits{MaxKeepAwake: time.Hour}.blocks(now, now.Add(-time.Hour)), "machine kept awake for too long")
+	assert.Empty(t, Limits{}.blocks(now, time.Time{}), "no limits by default")
+}
+
+func (suite *TestMover) TestStatus() {
+	t := suite.T()
+	mouseMover := GetInstance()
+	mouseMover.SetHeartbeatInterval(120)
+	assert.Equal(t, Status{Interval: 2 * time.Minute}, mouseMover.Status(), "nothing happened yet")
+
+	state := &state{
+		override: &override{
+			valueToReturn: true,
+		},
+	}
+	mouseMover.state = state
+	heartbeatCh := make(chan *tracker.Heartbeat)
+	mouseMover.run(heartbeatCh, suite.activityTracker)
+	heartbeatCh <- &tracker.Heartbeat{
+		WasAnyActivity: false,
+	}
+	time.Sleep(time.Millisecond * 500) //wait for it to be registered
+	mouseMover.Pause(time.Hour)
+
+	status := mouseMover.Status()
+	assert.True(t, status.Running)
+	assert.False(t, status.LastMoved.IsZero(), "mouse should have moved")
+	assert.False(t, status.LastActivity.IsZero(), "starting counts as activity")
+	assert.WithinDuration(t, time.Now().Add(time.Hour), status.PausedUntil, time.Second)
+	assert.False(t, status.Limited)
+
+	mouseMover.SetLimits(Limits{MaxKeepAwake: time.Hour})
+	assert.True(t, mouseMover.Status().Limited)
+}
+
+func (suite *TestMover) TestNextMove() {
+	t := suite.T()
+	now := time.Now()
+	status := Status{Running: true, Interval: time.Minute, LastActivity: now.Add(-20 * time.Second)}
+	assert.Equal(t, now.Add(40*time.Second), status.NextMove(now))
+
+	status.LastMoved = now.Add(-10 * time.Second)
+	assert.Equal(t, now.Add(50*time.Second), status.NextMove(now), "counts from the last move")
+
+	assert.Equal(t, now.Add(110*time.Second), status.NextMove(now.Add(70*time.Second)), "activity delays it to the next check")
+
+	status.PausedUntil = now.Add(time.Hour)
+	assert.True(t, status.NextMove(now).IsZero(), "no move while paused")
+	assert.True(t, Status{Interval: time.Minute}.NextMove(now).IsZero(), "no move while stopped")
+}
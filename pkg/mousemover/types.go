@@ -4,13 +4,21 @@ import (
 	"os"
 	"sync"
 	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
 )
 
 // MouseMover is the main struct for the app
 type MouseMover struct {
 	quit    chan struct{}
+	stopped chan struct{} //closed once the current run published its stop event
 	logFile *os.File
 	state   *state
+	events  *event.Bus
+	// seconds without activity before moving, 0 meaning the default
+	heartbeatInterval int
+	limits            Limits
+	strategy          string //Jiggle when empty
 }
 
 // state manages the internal working of the app
@@ -21,9 +29,23 @@ type state struct {
 	lastMouseMovedTime time.Time
 	lastErrorTime      time.Time
 	didNotMoveCount    int
+	pausedUntil        time.Time
+	lastActivityTime   time.Time
 	override           *override
 }
 
+// Status is a snapshot of what the mouse mover is doing
+type Status struct {
+	Running         bool
+	Sleeping        bool
+	PausedUntil     time.Time     //zero when not paused
+	LastMoved       time.Time     //zero if the mouse did not move yet
+	DidNotMoveCount int           //consecutive failed movements
+	LastActivity    time.Time     //last activity of the user seen while running
+	Interval        time.Duration //without activity before moving
+	Limited         bool          //the mouse only moves within time limits
+}
+
 // only needed for tests
 type override struct {
 	valueToReturn bool
@@ -0,0 +1,319 @@
+/*
+Package mqttbridge publishes the state of the mouse mover to an MQTT broker,
+announces it to Home Assistant through MQTT discovery and receives start, stop
+and pause commands back from it.
+
+Entities exposed for each machine:
+
+	binary_sensor  <name> at desk   ON while the user is active, OFF when away
+	switch         <name> AMM       ON while the mover is running, toggles it
+	sensor         <name> status    running, paused, sleeping or stopped
+	sensor         <name> last move timestamp of the last movement
+	sensor         <name> version   version of the app, the build details as attributes
+	button         <name> pause     pauses the mover for the default duration
+
+Commands are plain strings sent to <topicPrefix>/<node>/command: "start",
+"stop", "pause", or "pause <duration>" such as "pause 15m".
+*/
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/version"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	defaultTopicPrefix     = "amm"
+	defaultDiscoveryPrefix = "homeassistant"
+	defaultPauseDuration   = 30 * time.Minute
+	qos                    = 1
+)
+
+// Config of the MQTT connection, stored in the app settings
+type Config struct {
+	Broker          string `json:"broker"` //e.g. tcp://homeassistant.local:1883
+	ClientID        string `json:"clientId,omitempty"`
+	Username        string `json:"username,omitempty"`
+	Password        string `json:"password,omitempty"`
+	TopicPrefix     string `json:"topicPrefix,omitempty"`     //default "amm"
+	DiscoveryPrefix string `json:"discoveryPrefix,omitempty"` //default "homeassistant"
+	NodeID          string `json:"nodeId,omitempty"`          //default derived from the hostname
+}
+
+// Action requested through the command topic
+type Action string
+
+// Actions accepted on the command topic
+const (
+	Start Action = "start"
+	Stop  Action = "stop"
+	Pause Action = "pause"
+)
+
+// Command received from the broker
+type Command struct {
+	Action   Action
+	Duration time.Duration //only set for Pause
+}
+
+// Bridge is a connection to the broker for one machine
+type Bridge struct {
+	config   Config
+	client   mqtt.Client
+	commands chan Command
+	mutex    sync.Mutex
+	status   string
+}
+
+var invalidNodeChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// New validates the config and fills in defaults, without connecting yet
+func New(config Config) (*Bridge, error) {
+	if config.Broker == "" {
+		return nil, errors.New("mqtt: broker is required")
+	}
+	if config.TopicPrefix == "" {
+		config.TopicPrefix = defaultTopicPrefix
+	}
+	if config.DiscoveryPrefix == "" {
+		config.DiscoveryPrefix = defaultDiscoveryPrefix
+	}
+	if config.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil || hostname == "" {
+			hostname = "amm"
+		}
+		config.NodeID = hostname
+	}
+	config.NodeID = strings.Trim(invalidNodeChars.ReplaceAllString(config.NodeID, "_"), "_")
+	if config.ClientID == "" {
+		config.ClientID = "amm-" + config.NodeID
+	}
+	return &Bridge{
+		config:   config,
+		commands: make(chan Command, 8),
+		status:   "stopped",
+	}, nil
+}
+
+// Connect to the broker in the background, announcing the entities and
+// listening for commands once connected. It returns at once: the connection
+// is retried until the broker is reachable, and again whenever it is lost.
+func (b *Bridge) Connect() {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.config.Broker).
+		SetClientID(b.config.ClientID).
+		SetUsername(b.config.Username).
+		SetPassword(b.config.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetWill(b.topic("availability"), "offline", qos, true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			//runs again after every reconnection, the broker may have lost our state
+			b.announce(client)
+		})
+	b.client = mqtt.NewClient(opts)
+	b.client.Connect() //only completes once connected, nothing to wait for
+}
+
+// Commands returns the channel receiving the commands sent by the broker
+func (b *Bridge) Commands() <-chan Command {
+	return b.commands
+}
+
+// Handle updates the published state according to a mouse mover event
+func (b *Bridge) Handle(e event.Event) {
+	switch e.Type {
+	case event.Started, event.Stopped, event.Paused, event.Resumed, event.Sleep, event.Wake:
+		b.setStatus(moverStatus(e))
+	case event.Active:
+		b.publish("presence", "ON")
+	case event.Idle:
+		b.publish("presence", "OFF")
+	case event.Moved:
+		b.publish("last_move", e.Time.Format(time.RFC3339))
+	}
+}
+
+// Run forwards every event of the channel to Handle until it is closed
+func (b *Bridge) Run(events <-chan event.Event) {
+	for e := range events {
+		b.Handle(e)
+	}
+}
+
+// Close marks the machine offline and disconnects from the broker, or stops
+// trying to connect to it
+func (b *Bridge) Close() {
+	if b.client == nil {
+		return
+	}
+	if b.client.IsConnected() {
+		b.publish("availability", "offline")
+	}
+	b.client.Disconnect(250)
+}
+
+// moverStatus is the status published for the state of the mover attached to
+// the event, whatever the event is: waking up while paused is still paused
+func moverStatus(e event.Event) string {
+	switch {
+	case !e.Running:
+		return "stopped"
+	case e.Sleeping:
+		return "sleeping"
+	case e.Paused:
+		return "paused"
+	}
+	return "running"
+}
+
+func (b *Bridge) setStatus(status string) {
+	b.mutex.Lock()
+	b.status = status
+	b.mutex.Unlock()
+	b.publish("status", status)
+	if status == "stopped" {
+		b.publish("running", "OFF")
+	} else {
+		b.publish("running", "ON")
+	}
+}
+
+func (b *Bridge) announce(client mqtt.Client) {
+	for component, entities := range b.discoveryConfigs() {
+		for objectID, payload := range entities {
+			topic := fmt.Sprintf("%s/%s/%s/%s/config", b.config.DiscoveryPrefix, component, b.config.NodeID, objectID)
+			data, _ := json.Marshal(payload)
+			client.Publish(topic, qos, true, data)
+		}
+	}
+	client.Subscribe(b.topic("command"), qos, func(_ mqtt.Client, msg mqtt.Message) {
+		command, err := ParseCommand(string(msg.Payload()))
+		if err != nil {
+			return
+		}
+		select {
+		case b.commands <- command:
+		default: //the app is not keeping up, drop the command
+		}
+	})
+	b.mutex.Lock()
+	status := b.status
+	b.mutex.Unlock()
+	client.Publish(b.topic("availability"), qos, true, "online")
+	build, _ := json.Marshal(version.Get())
+	client.Publish(b.topic("version"), qos, true, build)
+	b.setStatus(status)
+}
+
+func (b *Bridge) discoveryConfigs() map[string]map[string]map[string]interface{} {
+	device := map[string]interface{}{
+		"identifiers":  []string{"amm_" + b.config.NodeID},
+		"name":         "Automatic Mouse Mover " + b.config.NodeID,
+		"manufacturer": "automatic-mouse-mover",
+		"sw_version":   version.Get().String(),
+	}
+	entity := func(objectID, name string, extra map[string]interface{}) map[string]interface{} {
+		payload := map[string]interface{}{
+			"name":               name,
+			"unique_id":          "amm_" + b.config.NodeID + "_" + objectID,
+			"availability_topic": b.topic("availability"),
+			"device":             device,
+		}
+		for k, v := range extra {
+			payload[k] = v
+		}
+		return payload
+	}
+	return map[string]map[string]map[string]interface{}{
+		"binary_sensor": {
+			"presence": entity("presence", "At desk", map[string]interface{}{
+				"state_topic":  b.topic("presence"),
+				"device_class": "presence",
+			}),
+		},
+		"switch": {
+			"running": entity("running", "AMM", map[string]interface{}{
+				"state_topic":   b.topic("running"),
+				"command_topic": b.topic("command"),
+				"payload_on":    string(Start),
+				"payload_off":   string(Stop),
+				"state_on":      "ON",
+				"state_off":     "OFF",
+			}),
+		},
+		"sensor": {
+			"status": entity("status", "Status", map[string]interface{}{
+				"state_topic": b.topic("status"),
+			}),
+			"last_move": entity("last_move", "Last move", map[string]interface{}{
+				"state_topic":  b.topic("last_move"),
+				"device_class": "timestamp",
+			}),
+			"version": entity("version", "Version", map[string]interface{}{
+				"state_topic":           b.topic("version"),
+				"value_template":        "{{ value_json.version }}",
+				"json_attributes_topic": b.topic("version"),
+				"entity_category":       "diagnostic",
+			}),
+		},
+		"button": {
+			"pause": entity("pause", "Pause", map[string]interface{}{
+				"command_topic": b.topic("command"),
+				"payload_press": string(Pause),
+			}),
+		},
+	}
+}
+
+func (b *Bridge) publish(subtopic string, payload string) {
+	if b.client == nil || !b.client.IsConnected() {
+		return
+	}
+	b.client.Publish(b.topic(subtopic), qos, true, payload)
+}
+
+func (b *Bridge) topic(subtopic string) string {
+	return b.config.TopicPrefix + "/" + b.config.NodeID + "/" + subtopic
+}
+
+// ParseCommand reads a payload sent to the command topic
+func ParseCommand(payload string) (Command, error) {
+	fields := strings.Fields(strings.ToLower(payload))
+	if len(fields) == 0 {
+		return Command{}, errors.New("mqtt: empty command")
+	}
+	command := Command{Action: Action(fields[0])}
+	switch command.Action {
+	case Start, Stop:
+		if len(fields) > 1 {
+			return Command{}, fmt.Errorf("mqtt: %s takes no argument", command.Action)
+		}
+	case Pause:
+		command.Duration = defaultPauseDuration
+		if len(fields) > 2 {
+			return Command{}, errors.New("mqtt: pause takes at most one duration")
+		}
+		if len(fields) == 2 {
+			duration, err := time.ParseDuration(fields[1])
+			if err != nil || duration <= 0 {
+				return Command{}, fmt.Errorf("mqtt: invalid pause duration %q", fields[1])
+			}
+			command.Duration = duration
+		}
+	default:
+		return Command{}, fmt.Errorf("mqtt: unknown command %q", payload)
+	}
+	return command, nil
+}
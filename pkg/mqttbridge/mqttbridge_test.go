@@ -0,0 +1,203 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/Resousse/automatic-mouse-mover/pkg/version"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startBroker runs an in-process broker and records every retained message
+func startBroker(t *testing.T) (server *mochi.Server, address string, retained func(topic string) (string, bool)) {
+	t.Helper()
+	server = mochi.New(&mochi.Options{InlineClient: true})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	require.NoError(t, server.AddListener(tcp))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+
+	var mutex sync.Mutex
+	messages := map[string]string{}
+	require.NoError(t, server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		messages[pk.TopicName] = string(pk.Payload)
+	}))
+	retained = func(topic string) (string, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		payload, ok := messages[topic]
+		return payload, ok
+	}
+	return server, "tcp://" + tcp.Address(), retained
+}
+
+func waitFor(t *testing.T, retained func(string) (string, bool), topic, want string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got, ok := retained(topic); ok && got == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	got, _ := retained(topic)
+	t.Fatalf("topic %s: expected %q, got %q", topic, want, got)
+}
+
+func TestNewDefaults(t *testing.T) {
+	_, err := New(Config{})
+	assert.Error(t, err, "broker should be required")
+
+	bridge, err := New(Config{Broker: "tcp://localhost:1883", NodeID: "my laptop.local"})
+	require.NoError(t, err)
+	assert.Equal(t, "my_laptop_local", bridge.config.NodeID)
+	assert.Equal(t, "amm-my_laptop_local", bridge.config.ClientID)
+	assert.Equal(t, "amm/my_laptop_local/status", bridge.topic("status"))
+}
+
+func TestParseCommand(t *testing.T) {
+	command, err := ParseCommand("start")
+	assert.NoError(t, err)
+	assert.Equal(t, Command{Action: Start}, command)
+
+	command, err = ParseCommand(" STOP ")
+	assert.NoError(t, err)
+	assert.Equal(t, Command{Action: Stop}, command)
+
+	command, err = ParseCommand("pause")
+	assert.NoError(t, err)
+	assert.Equal(t, Command{Action: Pause, Duration: defaultPauseDuration}, command)
+
+	command, err = ParseCommand("pause 15m")
+	assert.NoError(t, err)
+	assert.Equal(t, Command{Action: Pause, Duration: 15 * time.Minute}, command)
+
+	for _, payload := range []string{"", "jump", "start now", "pause soon", "pause -1m", "pause 1m 2m"} {
+		_, err = ParseCommand(payload)
+		assert.Error(t, err, "payload %q should be rejected", payload)
+	}
+}
+
+func TestDiscoveryAndState(t *testing.T) {
+	_, address, retained := startBroker(t)
+
+	bridge, err := New(Config{Broker: address, NodeID: "desk"})
+	require.NoError(t, err)
+	bridge.Connect()
+	defer bridge.Close()
+
+	waitFor(t, retained, "amm/desk/availability", "online")
+	waitFor(t, retained, "amm/desk/status", "stopped")
+
+	config, ok := retained("homeassistant/binary_sensor/desk/presence/config")
+	require.True(t, ok, "presence discovery config should be published")
+	var discovery map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(config), &discovery))
+	assert.Equal(t, "amm/desk/presence", discovery["state_topic"])
+	assert.Equal(t, "amm_desk_presence", discovery["unique_id"])
+	device, _ := discovery["device"].(map[string]interface{})
+	assert.Equal(t, version.Get().String(), device["sw_version"], "bug reports need the exact build")
+	build, ok := retained("amm/desk/version")
+	require.True(t, ok, "the build should be published")
+	var info version.Info
+	require.NoError(t, json.Unmarshal([]byte(build), &info))
+	assert.Equal(t, version.Get(), info)
+	for _, topic := range []string{
+		"homeassistant/switch/desk/running/config",
+		"homeassistant/sensor/desk/status/config",
+		"homeassistant/sensor/desk/last_move/config",
+		"homeassistant/sensor/desk/version/config",
+		"homeassistant/button/desk/pause/config",
+	} {
+		_, ok := retained(topic)
+		assert.True(t, ok, "%s should be published", topic)
+	}
+
+	bridge.Handle(event.Event{Type: event.Started, Running: true})
+	waitFor(t, retained, "amm/desk/status", "running")
+	waitFor(t, retained, "amm/desk/running", "ON")
+
+	bridge.Handle(event.Event{Type: event.Idle})
+	waitFor(t, retained, "amm/desk/presence", "OFF")
+	bridge.Handle(event.Event{Type: event.Active})
+	waitFor(t, retained, "amm/desk/presence", "ON")
+
+	movedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	bridge.Handle(event.Event{Type: event.Moved, Time: movedAt})
+	waitFor(t, retained, "amm/desk/last_move", "2024-05-01T10:00:00Z")
+
+	bridge.Handle(event.Event{Type: event.Paused, Running: true, Paused: true})
+	waitFor(t, retained, "amm/desk/status", "paused")
+	bridge.Handle(event.Event{Type: event.Sleep, Running: true, Paused: true, Sleeping: true})
+	waitFor(t, retained, "amm/desk/status", "sleeping")
+	bridge.Handle(event.Event{Type: event.Wake, Running: true, Paused: true})
+	waitFor(t, retained, "amm/desk/status", "paused")
+
+	bridge.Handle(event.Event{Type: event.Stopped})
+	waitFor(t, retained, "amm/desk/status", "stopped")
+	waitFor(t, retained, "amm/desk/running", "OFF")
+
+	bridge.Close()
+	waitFor(t, retained, "amm/desk/availability", "offline")
+}
+
+func TestConnectDoesNotWaitForTheBroker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := "tcp://" + listener.Addr().String()
+	require.NoError(t, listener.Close()) //nothing listens there anymore
+
+	bridge, err := New(Config{Broker: address, NodeID: "desk"})
+	require.NoError(t, err)
+	start := time.Now()
+	bridge.Connect()
+	bridge.Handle(event.Event{Type: event.Started, Running: true})
+	assert.Less(t, time.Since(start), time.Second, "the app should not wait for the broker")
+
+	closed := make(chan struct{})
+	go func() {
+		bridge.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close should stop the connection attempts")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	server, address, retained := startBroker(t)
+
+	bridge, err := New(Config{Broker: address, NodeID: "desk"})
+	require.NoError(t, err)
+	bridge.Connect()
+	defer bridge.Close()
+	waitFor(t, retained, "amm/desk/availability", "online")
+
+	for _, payload := range []string{"bogus", "pause 5m", "stop"} {
+		require.NoError(t, server.Publish("amm/desk/command", []byte(payload), false, 1))
+	}
+
+	expected := []Command{{Action: Pause, Duration: 5 * time.Minute}, {Action: Stop}}
+	for _, want := range expected {
+		select {
+		case got := <-bridge.Commands():
+			assert.Equal(t, want, got)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("did not receive command %v", want)
+		}
+	}
+}
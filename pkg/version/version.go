@@ -0,0 +1,105 @@
+/*
+Package version tells which build of the app is running.
+
+Release builds set the version, commit and date with the linker:
+
+	go build -ldflags "-X github.com/Resousse/automatic-mouse-mover/pkg/version.version=v1.5.0
+	  -X github.com/Resousse/automatic-mouse-mover/pkg/version.commit=$(git rev-parse HEAD)
+	  -X github.com/Resousse/automatic-mouse-mover/pkg/version.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+Otherwise they come from what the Go toolchain records in the binary: the
+module version for go install, and the VCS revision and time for builds made
+from a git checkout.
+*/
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Dev is the version of builds whose version is unknown
+const Dev = "dev"
+
+// set with -ldflags "-X ..."
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// Info describes the build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"` //RFC 3339
+	GoVersion string `json:"goVersion"`
+	Modified  bool   `json:"modified,omitempty"` //built with uncommitted changes
+}
+
+// Get returns the information of the running build
+func Get() Info {
+	bi, _ := debug.ReadBuildInfo()
+	return build(bi, version, commit, date)
+}
+
+// build merges the values set by the linker with the build info, the
+// former winning
+func build(bi *debug.BuildInfo, version, commit, date string) Info {
+	info := Info{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+	if bi != nil {
+		if bi.GoVersion != "" {
+			info.GoVersion = bi.GoVersion
+		}
+		if info.Version == "" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				//only about the recorded revision, not one set by the linker
+				info.Modified = commit == "" && setting.Value == "true"
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = Dev
+	}
+	return info
+}
+
+// ShortCommit returns the first characters of the commit
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 7 {
+		return i.Commit[:7]
+	}
+	return i.Commit
+}
+
+// String returns the version followed by the details known, e.g.
+// v1.5.0 (commit 1a2b3c4, 2026-10-18T09:00:00Z, go1.25.3)
+func (i Info) String() string {
+	details := []string{}
+	if i.Commit != "" {
+		commit := "commit " + i.ShortCommit()
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, commit)
+	}
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+	details = append(details, i.GoVersion)
+	return fmt.Sprintf("%v (%v)", i.Version, strings.Join(details, ", "))
+}
@@ -0,0 +1,44 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.25.3",
+		Main:      debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "1a2b3c4d5e6f"},
+			{Key: "vcs.time", Value: "2026-10-18T09:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	info := build(bi, "", "", "")
+	assert.Equal(t, Info{Version: Dev, Commit: "1a2b3c4d5e6f", Date: "2026-10-18T09:00:00Z", GoVersion: "go1.25.3", Modified: true}, info)
+	assert.Equal(t, "dev (commit 1a2b3c4-dirty, 2026-10-18T09:00:00Z, go1.25.3)", info.String())
+
+	info = build(bi, "v1.5.0", "ffffffff", "2026-10-19T00:00:00Z")
+	assert.Equal(t, "v1.5.0", info.Version, "the linker wins")
+	assert.Equal(t, "ffffffff", info.Commit)
+	assert.Equal(t, "2026-10-19T00:00:00Z", info.Date)
+	assert.False(t, info.Modified, "the changes are not those of the commit of the linker")
+	assert.Equal(t, "v1.5.0 (commit fffffff, 2026-10-19T00:00:00Z, go1.25.3)", info.String())
+
+	bi.Main.Version = "v1.4.2"
+	assert.Equal(t, "v1.4.2", build(bi, "", "", "").Version, "go install records the module version")
+
+	info = build(nil, "", "", "")
+	assert.Equal(t, Info{Version: Dev, GoVersion: runtime.Version()}, info)
+	assert.Equal(t, "dev ("+runtime.Version()+")", info.String())
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	assert.NotEmpty(t, info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+}
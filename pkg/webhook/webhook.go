@@ -0,0 +1,292 @@
+/*
+Package webhook notifies HTTP endpoints of the mouse mover events.
+
+Each target receives a JSON POST for the events it subscribed to. The body is
+either the default payload below or the result of the target's template:
+
+	{"event":"move-failed","time":"2024-05-01T10:00:00Z","host":"laptop",
+	 "lastMoved":"2024-05-01T09:59:00Z","didNotMoveCount":3,"message":"..."}
+
+Templates are text/template strings executed with a Payload, so "{{.Event}}"
+or "{{json .Message}}" can be used; the output must be valid JSON.
+
+When a secret is set, the body is signed with HMAC-SHA256 and the hex digest
+is sent in the X-AMM-Signature-256 header as "sha256=<digest>".
+*/
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+	requestTimeout    = 10 * time.Second
+	queueSize         = 64
+
+	// SignatureHeader carries the HMAC-SHA256 signature of the body
+	SignatureHeader = "X-AMM-Signature-256"
+	// EventHeader carries the event type
+	EventHeader = "X-AMM-Event"
+)
+
+// Target is a webhook endpoint, stored in the app settings
+type Target struct {
+	URL        string       `json:"url"`
+	Events     []event.Type `json:"events,omitempty"`     //empty means every event
+	Template   string       `json:"template,omitempty"`   //empty means the default payload
+	Secret     string       `json:"secret,omitempty"`     //HMAC key, no signature when empty
+	MaxRetries *int         `json:"maxRetries,omitempty"` //default 3
+}
+
+// Payload is the data available to templates, and the default body
+type Payload struct {
+	Event           event.Type `json:"event"`
+	Time            time.Time  `json:"time"`
+	Host            string     `json:"host"`
+	LastMoved       *time.Time `json:"lastMoved,omitempty"`
+	DidNotMoveCount int        `json:"didNotMoveCount"`
+	Message         string     `json:"message,omitempty"`
+}
+
+// Dispatcher delivers the events to every target, in order per target
+type Dispatcher struct {
+	targets []*target
+	client  *http.Client
+	backoff time.Duration //delay before the first retry, doubled after each one
+	host    string
+	wg      sync.WaitGroup
+}
+
+type target struct {
+	Target
+	template   *template.Template
+	maxRetries int
+	queue      chan Payload
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Validate checks the targets without starting anything
+func Validate(targets []Target) error {
+	for i, config := range targets {
+		if _, err := newTarget(config); err != nil {
+			return fmt.Errorf("webhook %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// New validates the targets and starts one delivery worker for each of them
+func New(targets []Target) (*Dispatcher, error) {
+	host, _ := os.Hostname()
+	d := &Dispatcher{
+		client:  &http.Client{Timeout: requestTimeout},
+		backoff: defaultBackoff,
+		host:    host,
+	}
+	for i, config := range targets {
+		t, err := newTarget(config)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %d: %w", i+1, err)
+		}
+		d.targets = append(d.targets, t)
+	}
+	for _, t := range d.targets {
+		d.wg.Add(1)
+		go d.deliverAll(t)
+	}
+	return d, nil
+}
+
+func newTarget(config Target) (*target, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", config.URL)
+	}
+	t := &target{
+		Target:     config,
+		maxRetries: defaultMaxRetries,
+		queue:      make(chan Payload, queueSize),
+	}
+	if config.MaxRetries != nil {
+		if *config.MaxRetries < 0 {
+			return nil, errors.New("maxRetries cannot be negative")
+		}
+		t.maxRetries = *config.MaxRetries
+	}
+	for _, wanted := range config.Events {
+		if !known(wanted) {
+			names := make([]string, len(event.Types))
+			for i, eventType := range event.Types {
+				names[i] = string(eventType)
+			}
+			return nil, fmt.Errorf("unknown event %q, expected one of: %v", wanted, strings.Join(names, ", "))
+		}
+	}
+	if config.Template != "" {
+		t.template, err = template.New(config.URL).Funcs(templateFuncs).Parse(config.Template)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Handle queues the event for the targets interested in it
+func (d *Dispatcher) Handle(e event.Event) {
+	payload := Payload{
+		Event:           e.Type,
+		Time:            e.Time,
+		Host:            d.host,
+		DidNotMoveCount: e.DidNotMoveCount,
+		Message:         e.Message,
+	}
+	if !e.LastMoved.IsZero() {
+		payload.LastMoved = &e.LastMoved
+	}
+	for _, t := range d.targets {
+		if !t.wants(e.Type) {
+			continue
+		}
+		select {
+		case t.queue <- payload:
+		default:
+			log.Warnf("webhook %v: queue full, dropping %v event", t.URL, e.Type)
+		}
+	}
+}
+
+// Run forwards every event of the channel to Handle until it is closed
+func (d *Dispatcher) Run(events <-chan event.Event) {
+	for e := range events {
+		d.Handle(e)
+	}
+}
+
+// Close waits for the queued deliveries to finish. Handle must not be
+// called afterwards.
+func (d *Dispatcher) Close() {
+	for _, t := range d.targets {
+		close(t.queue)
+	}
+	d.wg.Wait()
+}
+
+// known tells if the mouse mover emits events of that type
+func known(eventType event.Type) bool {
+	for _, known := range event.Types {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *target) wants(eventType event.Type) bool {
+	if len(t.Events) == 0 {
+		return true
+	}
+	for _, wanted := range t.Events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *target) body(payload Payload) ([]byte, error) {
+	if t.template == nil {
+		return json.Marshal(payload)
+	}
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, payload); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *Dispatcher) deliverAll(t *target) {
+	defer d.wg.Done()
+	for payload := range t.queue {
+		body, err := t.body(payload)
+		if err != nil {
+			log.Errorf("webhook %v: %v", t.URL, err)
+			continue
+		}
+		if err := d.deliver(t, payload.Event, body); err != nil {
+			log.Errorf("webhook %v: giving up on %v event: %v", t.URL, payload.Event, err)
+		}
+	}
+}
+
+// deliver posts the body, retrying with an exponential backoff on network
+// errors, 429 and 5xx responses
+func (d *Dispatcher) deliver(t *target, eventType event.Type, body []byte) error {
+	backoff := d.backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = d.post(t, eventType, body)
+		if err == nil || !retry || attempt >= t.maxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) post(t *target, eventType event.Type, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(eventType))
+	if t.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(t.Secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("unexpected status %v", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the body, as sent in
+// SignatureHeader without its "sha256=" prefix
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Resousse/automatic-mouse-mover/pkg/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// recorder answers with the given status codes in turn, then 200
+func recorder(t *testing.T, statuses ...int) (*httptest.Server, func() []request) {
+	t.Helper()
+	var mutex sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, request{r.Header.Clone(), body})
+		status := http.StatusOK
+		if len(requests) <= len(statuses) {
+			status = statuses[len(requests)-1]
+		}
+		mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []request {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]request(nil), requests...)
+	}
+}
+
+func newDispatcher(t *testing.T, targets ...Target) *Dispatcher {
+	t.Helper()
+	d, err := New(targets)
+	require.NoError(t, err)
+	d.backoff = time.Millisecond
+	return d
+}
+
+func TestDefaultPayloadAndFilter(t *testing.T) {
+	server, requests := recorder(t)
+	d := newDispatcher(t, Target{URL: server.URL, Events: []event.Type{event.Started, event.MoveFailed}})
+
+	lastMoved := time.Date(2024, 5, 1, 9, 59, 0, 0, time.UTC)
+	d.Handle(event.Event{Type: event.Started, Time: lastMoved})
+	d.Handle(event.Event{Type: event.Moved, Time: lastMoved})
+	d.Handle(event.Event{Type: event.MoveFailed, Time: lastMoved.Add(time.Minute), LastMoved: lastMoved, DidNotMoveCount: 2, Message: "stuck"})
+	d.Close()
+
+	got := requests()
+	require.Len(t, got, 2, "moved event should be filtered out")
+	assert.Equal(t, "start", got[0].header.Get(EventHeader))
+	assert.Equal(t, "application/json", got[0].header.Get("Content-Type"))
+	assert.Empty(t, got[0].header.Get(SignatureHeader), "no signature without secret")
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal(got[1].body, &payload))
+	assert.Equal(t, event.MoveFailed, payload.Event)
+	assert.Equal(t, 2, payload.DidNotMoveCount)
+	assert.Equal(t, "stuck", payload.Message)
+	require.NotNil(t, payload.LastMoved)
+	assert.True(t, lastMoved.Equal(*payload.LastMoved))
+}
+
+func TestTemplateAndSignature(t *testing.T) {
+	server, requests := recorder(t)
+	d := newDispatcher(t, Target{
+		URL:      server.URL,
+		Template: `{"text": {{json (printf "amm %s on %s" .Event .Host)}}}`,
+		Secret:   "s3cret",
+	})
+	d.Handle(event.Event{Type: event.Sleep})
+	d.Close()
+
+	got := requests()
+	require.Len(t, got, 1)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(got[0].body, &body))
+	assert.Equal(t, "amm sleep on "+d.host, body["text"])
+	assert.Equal(t, "sha256="+Sign("s3cret", got[0].body), got[0].header.Get(SignatureHeader))
+}
+
+func TestInvalidTemplateOutputIsNotSent(t *testing.T) {
+	server, requests := recorder(t)
+	d := newDispatcher(t, Target{URL: server.URL, Template: `not json {{.Event}}`})
+	d.Handle(event.Event{Type: event.Stopped})
+	d.Close()
+	assert.Empty(t, requests())
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	server, requests := recorder(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	d := newDispatcher(t, Target{URL: server.URL})
+	d.Handle(event.Event{Type: event.Started})
+	d.Close()
+	assert.Len(t, requests(), 3, "should retry until success")
+}
+
+func TestRetryLimitAndClientErrors(t *testing.T) {
+	maxRetries := 1
+	server, requests := recorder(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	d := newDispatcher(t, Target{URL: server.URL, MaxRetries: &maxRetries})
+	d.Handle(event.Event{Type: event.Started})
+	d.Close()
+	assert.Len(t, requests(), 2, "should stop after maxRetries")
+
+	server, requests = recorder(t, http.StatusBadRequest)
+	d = newDispatcher(t, Target{URL: server.URL})
+	d.Handle(event.Event{Type: event.Started})
+	d.Close()
+	assert.Len(t, requests(), 1, "client errors should not be retried")
+}
+
+func TestNewValidation(t *testing.T) {
+	negative := -1
+	for _, target := range []Target{
+		{URL: ""},
+		{URL: "ftp://example.com"},
+		{URL: "http://"},
+		{URL: "http://example.com", Template: "{{.Event"},
+		{URL: "http://example.com", MaxRetries: &negative},
+		{URL: "http://example.com", Events: []event.Type{event.Moved, "moved"}},
+	} {
+		_, err := New([]Target{target})
+		assert.Error(t, err, "%+v should be rejected", target)
+	}
+
+	err := Validate([]Target{{URL: "http://example.com", Events: []event.Type{"moved"}}})
+	assert.EqualError(t, err, `webhook 1: unknown event "moved", expected one of: start, stop, pause, resume, move, move-failed, sleep, wake, idle, active`)
+}